@@ -23,6 +23,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []token.Token // the // comments of the source, for tools such as the formatter
 }
 
 func (p *Program) TokenLiteral() string {
@@ -46,6 +47,7 @@ func (p *Program) ToString() string {
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
+	Type  TypeExpression // nil without an annotation
 	Value Expression
 }
 
@@ -86,6 +88,9 @@ func (ls *LetStatement) ToString() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.ToString())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.ToString())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.ToString())
@@ -165,6 +170,19 @@ func (oe *InfixExpression) ToString() string {
 	return out.String()
 }
 
+// PostfixExpression is an operator following its operand, as in f()?
+type PostfixExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) ToString() string {
+	return "(" + pe.Left.ToString() + pe.Operator + ")"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -199,9 +217,56 @@ func (ife *IfExpression) ToString() string {
 	return out.String()
 }
 
+// MatchExpression evaluates the body of the first case whose pattern fits
+// the subject and whose guard, if any, holds.
+type MatchExpression struct {
+	Token   token.Token // match token
+	Subject Expression
+	Cases   []*MatchCase
+	Rbrace  token.Token
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) ToString() string {
+	var out bytes.Buffer
+
+	out.WriteString("match")
+	out.WriteString(me.Subject.ToString())
+	out.WriteString(" { ")
+	for _, c := range me.Cases {
+		out.WriteString(c.ToString() + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// MatchCase is a case of a match, as in case Rect(w, h) if w == h { w * w }.
+type MatchCase struct {
+	Token   token.Token // case token
+	Pattern Pattern
+	Guard   Expression // nil when the case has no guard
+	Body    *BlockStatement
+}
+
+func (mc *MatchCase) TokenLiteral() string { return mc.Token.Literal }
+func (mc *MatchCase) ToString() string {
+	var out bytes.Buffer
+
+	out.WriteString("case " + mc.Pattern.ToString())
+	if mc.Guard != nil {
+		out.WriteString(" if " + mc.Guard.ToString())
+	}
+	out.WriteString(" " + mc.Body.ToString())
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token // { token
 	Statements []Statement
+	Rbrace     token.Token // } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -217,10 +282,20 @@ func (bs *BlockStatement) ToString() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token // fn token
-	Name       *Identifier
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token // fn token
+	Name           *Identifier
+	Parameters     []*Identifier
+	ParameterTypes []TypeExpression // parallel to Parameters, nil entries for parameters without annotation
+	ReturnType     TypeExpression   // nil without an annotation
+	Body           *BlockStatement
+}
+
+// ParameterType returns the annotation of the i-th parameter, or nil.
+func (fl *FunctionLiteral) ParameterType(i int) TypeExpression {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -229,14 +304,21 @@ func (fl *FunctionLiteral) ToString() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.ToString())
+	for i, p := range fl.Parameters {
+		if typ := fl.ParameterType(i); typ != nil {
+			params = append(params, p.ToString()+": "+typ.ToString())
+		} else {
+			params = append(params, p.ToString())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.ToString() + " ")
+	}
 	out.WriteString(fl.Body.ToString())
 
 	return out.String()
@@ -246,6 +328,7 @@ type CallExpression struct {
 	Token     token.Token // ( token after the identifier
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -278,6 +361,7 @@ func (str *StringLiteral) ToString() string     { return str.Token.Literal }
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Rbracket token.Token
 }
 
 func (arr *ArrayLiteral) expressionNode()      {}
@@ -296,3 +380,212 @@ func (arr *ArrayLiteral) ToString() string {
 
 	return out.String()
 }
+
+type MemberExpression struct {
+	Token    token.Token // . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) ToString() string {
+	return me.Object.ToString() + "." + me.Property.ToString()
+}
+
+type AssignExpression struct {
+	Token  token.Token // = token
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) ToString() string {
+	return ae.Target.ToString() + " = " + ae.Value.ToString()
+}
+
+type ThrowStatement struct {
+	Token token.Token // throw token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) ToString() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.ToString())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type TryExpression struct {
+	Token      token.Token // try token
+	Block      *BlockStatement
+	CatchParam *Identifier // nil without a catch clause
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) ToString() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.ToString())
+	if te.Catch != nil {
+		out.WriteString(" catch(" + te.CatchParam.ToString() + ") ")
+		out.WriteString(te.Catch.ToString())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.ToString())
+	}
+
+	return out.String()
+}
+
+type MacroLiteral struct {
+	Token      token.Token // macro token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) ToString() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.ToString())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.ToString())
+
+	return out.String()
+}
+
+// StructStatement declares a struct type, as in struct Point { x, y }.
+type StructStatement struct {
+	Token  token.Token // struct token
+	Name   *Identifier
+	Fields []*Identifier
+	Rbrace token.Token
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) ToString() string {
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.ToString())
+	}
+	return "struct " + ss.Name.ToString() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+// StructLiteral builds a struct value, as in Point{x: 1, y: 2}.
+type StructLiteral struct {
+	Token  token.Token // { token
+	Name   *Identifier
+	Fields []*Identifier
+	Values []Expression // parallel to Fields
+	Rbrace token.Token
+}
+
+func (sl *StructLiteral) expressionNode()      {}
+func (sl *StructLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StructLiteral) ToString() string {
+	fields := []string{}
+	for i, f := range sl.Fields {
+		fields = append(fields, f.ToString()+": "+sl.Values[i].ToString())
+	}
+	return sl.Name.ToString() + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// ExtendStatement adds methods to a type, as in
+// extend STRING { fn shout(self) { self + "!" } }. The first parameter
+// of a method is the receiver.
+type ExtendStatement struct {
+	Token   token.Token // extend token
+	Type    *Identifier
+	Traits  []*Identifier // the traits the type must implement once extended
+	Methods []*FunctionLiteral
+	Rbrace  token.Token
+}
+
+func (es *ExtendStatement) statementNode()       {}
+func (es *ExtendStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExtendStatement) ToString() string {
+	var out bytes.Buffer
+
+	out.WriteString("extend " + es.Type.ToString())
+	if len(es.Traits) > 0 {
+		traits := []string{}
+		for _, t := range es.Traits {
+			traits = append(traits, t.ToString())
+		}
+		out.WriteString(": " + strings.Join(traits, ", "))
+	}
+	out.WriteString(" { ")
+	for _, m := range es.Methods {
+		out.WriteString("fn " + m.Name.ToString() + strings.TrimPrefix(m.ToString(), "fn") + " ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// TraitStatement declares the methods a type must have, as in trait Shape { area, perimeter }.
+type TraitStatement struct {
+	Token   token.Token // trait token
+	Name    *Identifier
+	Methods []*Identifier
+	Rbrace  token.Token
+}
+
+func (ts *TraitStatement) statementNode()       {}
+func (ts *TraitStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TraitStatement) ToString() string {
+	methods := []string{}
+	for _, m := range ts.Methods {
+		methods = append(methods, m.ToString())
+	}
+	return "trait " + ts.Name.ToString() + " { " + strings.Join(methods, ", ") + " }"
+}
+
+// EnumStatement declares an enum type, as in enum Shape { Circle(r), Rect(w, h), Empty }.
+type EnumStatement struct {
+	Token    token.Token // enum token
+	Name     *Identifier
+	Variants []*Identifier
+	Fields   [][]*Identifier // parallel to Variants, empty for variants without fields
+	Rbrace   token.Token
+}
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) ToString() string {
+	variants := []string{}
+	for i, v := range es.Variants {
+		if len(es.Fields[i]) == 0 {
+			variants = append(variants, v.ToString())
+			continue
+		}
+		fields := []string{}
+		for _, f := range es.Fields[i] {
+			fields = append(fields, f.ToString())
+		}
+		variants = append(variants, v.ToString()+"("+strings.Join(fields, ", ")+")")
+	}
+	return "enum " + es.Name.ToString() + " { " + strings.Join(variants, ", ") + " }"
+}
@@ -1,7 +1,13 @@
 package ast
 
 import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
 	"morty/token"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -25,3 +31,253 @@ func TestToString(t *testing.T) {
 		t.Errorf("program.toString() wrong. got=%q", program.ToString())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Noder) Noder {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Noder
+		expected Noder
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		{&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Concequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Concequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{&LetStatement{Value: one()}, &LetStatement{Value: two()}},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one()}}, &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two()}}},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal, got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+// everyNode builds a tree holding at least one node of every type, with all
+// optional children present.
+func everyNode() *Program {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	integer := func(v int64) *IntegerLiteral { return &IntegerLiteral{Value: v} }
+	named := func(name string) *NamedType { return &NamedType{Name: name} }
+	block := func(exps ...Expression) *BlockStatement {
+		b := &BlockStatement{Statements: []Statement{}}
+		for _, exp := range exps {
+			b.Statements = append(b.Statements, &ExpressionStatement{Expression: exp})
+		}
+		return b
+	}
+
+	return &Program{Statements: []Statement{
+		&LetStatement{Name: ident("a"), Value: &PrefixExpression{Operator: "-", Right: integer(1)}},
+		&ReturnStatement{ReturnValue: &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)}},
+		&ThrowStatement{Value: &StringLiteral{Value: "boom"}},
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition:   &Boolean{Value: true},
+			Concequence: block(integer(3)),
+			Alternative: block(integer(4)),
+		}},
+		&ExpressionStatement{Expression: &FunctionLiteral{
+			Name:           ident("f"),
+			Parameters:     []*Identifier{ident("x")},
+			ParameterTypes: []TypeExpression{&ArrayType{Element: named("int")}},
+			ReturnType: &UnionType{Types: []TypeExpression{
+				&FunctionType{Parameters: []TypeExpression{named("string")}, Return: named("bool")},
+				named("null"),
+			}},
+			Body: block(&PostfixExpression{Left: ident("x"), Operator: "?"}),
+		}},
+		&ExpressionStatement{Expression: &MacroLiteral{Parameters: []*Identifier{ident("y")}, Body: block(ident("y"))}},
+		&StructStatement{Name: ident("Point"), Fields: []*Identifier{ident("x"), ident("y")}},
+		&TraitStatement{Name: ident("Shape"), Methods: []*Identifier{ident("area")}},
+		&ExtendStatement{
+			Type:    ident("Point"),
+			Traits:  []*Identifier{ident("Shape")},
+			Methods: []*FunctionLiteral{{Name: ident("area"), Parameters: []*Identifier{ident("self")}, Body: block(ident("self"))}},
+		},
+		&EnumStatement{
+			Name:     ident("Shape"),
+			Variants: []*Identifier{ident("Rect"), ident("Empty")},
+			Fields:   [][]*Identifier{{ident("w")}, {}},
+		},
+		&ExpressionStatement{Expression: &MatchExpression{
+			Subject: ident("s"),
+			Cases: []*MatchCase{
+				{
+					Pattern: &VariantPattern{Name: ident("Rect"), Fields: []Pattern{&BindingPattern{Name: ident("w")}}},
+					Guard:   &Boolean{Value: true},
+					Body:    block(ident("w")),
+				},
+				{
+					Pattern: &ArrayPattern{Elements: []Pattern{&LiteralPattern{Value: integer(0)}, &WildcardPattern{}}},
+					Body:    block(integer(0)),
+				},
+			},
+		}},
+		&ExpressionStatement{Expression: &StructLiteral{Name: ident("Point"), Fields: []*Identifier{ident("x")}, Values: []Expression{integer(1)}}},
+		&ExpressionStatement{Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{
+			&ArrayLiteral{Elements: []Expression{integer(5)}},
+		}}},
+		&ExpressionStatement{Expression: &AssignExpression{
+			Target: &MemberExpression{Object: ident("p"), Property: ident("q")},
+			Value:  integer(6),
+		}},
+		&ExpressionStatement{Expression: &TryExpression{
+			Block:      block(integer(7)),
+			CatchParam: ident("e"),
+			Catch:      block(integer(8)),
+			Finally:    block(integer(9)),
+		}},
+	}}
+}
+
+// nodeTypes returns the names of all the node types declared in this package.
+func nodeTypes(t *testing.T) map[string]bool {
+	fset := gotoken.NewFileSet()
+	pkgs, err := goparser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("parsing package: %s", err)
+	}
+
+	types := map[string]bool{}
+	for _, file := range pkgs["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "ToString" {
+				continue
+			}
+			recv := fn.Recv.List[0].Type.(*goast.StarExpr).X.(*goast.Ident)
+			types[recv.Name] = true
+		}
+	}
+	return types
+}
+
+// checkChildrenSeen fails if a child of a seen node was not seen itself.
+func checkChildrenSeen(t *testing.T, fn string, seen map[Noder]bool) {
+	noderType := reflect.TypeOf((*Noder)(nil)).Elem()
+
+	for node := range seen {
+		v := reflect.ValueOf(node).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			children := []reflect.Value{field}
+			if field.Kind() == reflect.Slice {
+				children = children[:0]
+				for j := 0; j < field.Len(); j++ {
+					children = append(children, field.Index(j))
+				}
+			}
+
+			for _, child := range children {
+				if !child.Type().Implements(noderType) || child.IsNil() {
+					continue
+				}
+				if !seen[child.Interface().(Noder)] {
+					t.Errorf("%s skipped %s.%s", fn, v.Type().Name(), v.Type().Field(i).Name)
+				}
+			}
+		}
+	}
+}
+
+func TestWalkCoversEveryNode(t *testing.T) {
+	seen := map[Noder]bool{}
+	seenTypes := map[string]bool{}
+	Inspect(everyNode(), func(node Noder) bool {
+		if node != nil {
+			seen[node] = true
+			seenTypes[reflect.TypeOf(node).Elem().Name()] = true
+		}
+		return true
+	})
+
+	for name := range nodeTypes(t) {
+		if !seenTypes[name] {
+			t.Errorf("Walk never reached a %s, add it to everyNode", name)
+		}
+	}
+	checkChildrenSeen(t, "Walk", seen)
+}
+
+func TestModifyCoversEveryNode(t *testing.T) {
+	seen := map[Noder]bool{}
+	Modify(everyNode(), func(node Noder) Noder {
+		seen[node] = true
+		return node
+	})
+
+	checkChildrenSeen(t, "Modify", seen)
+}
+
+func TestCopy(t *testing.T) {
+	original := everyNode()
+	seen := map[Noder]bool{}
+	Inspect(original, func(node Noder) bool {
+		seen[node] = true
+		return true
+	})
+
+	copied := Copy(original)
+	if copied.ToString() != original.ToString() {
+		t.Errorf("copy differs, expected=%q, got=%q", original.ToString(), copied.ToString())
+	}
+	Inspect(copied, func(node Noder) bool {
+		if node != nil && seen[node] {
+			t.Errorf("copy shares a %T with the original", node)
+		}
+		return true
+	})
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	var idents []string
+	Inspect(everyNode(), func(node Noder) bool {
+		if ident, ok := node.(*Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+
+	expected := []string{"a", "y", "y", "Point", "x", "y", "Shape", "area", "Point", "Shape", "Shape", "Rect", "w", "Empty", "s", "Rect", "w", "w", "Point", "x", "f", "p", "q", "e"}
+	if !reflect.DeepEqual(idents, expected) {
+		t.Errorf("wrong identifiers, expected=%q, got=%q", expected, idents)
+	}
+}
@@ -0,0 +1,204 @@
+package ast
+
+type ModifierFunc func(Noder) Noder
+
+// Modify replaces every node below node, children first, with the result of
+// calling modifier on it, and returns modifier(node). A child replaced by a
+// node of the wrong kind for its place becomes nil. Optional children that
+// are absent are skipped.
+func Modify(node Noder, modifier ModifierFunc) Noder {
+	switch node := node.(type) {
+
+	case *Program:
+		modifyStatements(node.Statements, modifier)
+
+	case *LetStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		node.Type = modifyType(node.Type, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+
+	case *ThrowStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+
+	case *BlockStatement:
+		modifyStatements(node.Statements, modifier)
+
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+
+	case *PostfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Concequence, _ = Modify(node.Concequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+
+	case *MatchExpression:
+		node.Subject = modifyExpression(node.Subject, modifier)
+		for i, c := range node.Cases {
+			node.Cases[i], _ = Modify(c, modifier).(*MatchCase)
+		}
+
+	case *MatchCase:
+		node.Pattern = modifyPattern(node.Pattern, modifier)
+		node.Guard = modifyExpression(node.Guard, modifier)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *FunctionLiteral:
+		if node.Name != nil {
+			node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		}
+		modifyIdentifiers(node.Parameters, modifier)
+		modifyTypes(node.ParameterTypes, modifier)
+		node.ReturnType = modifyType(node.ReturnType, modifier)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *MacroLiteral:
+		modifyIdentifiers(node.Parameters, modifier)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		modifyExpressions(node.Arguments, modifier)
+
+	case *ArrayLiteral:
+		modifyExpressions(node.Elements, modifier)
+
+	case *MemberExpression:
+		node.Object = modifyExpression(node.Object, modifier)
+		node.Property, _ = Modify(node.Property, modifier).(*Identifier)
+
+	case *AssignExpression:
+		node.Target = modifyExpression(node.Target, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+
+	case *TryExpression:
+		node.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.CatchParam, _ = Modify(node.CatchParam, modifier).(*Identifier)
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+
+	case *StructStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		modifyIdentifiers(node.Fields, modifier)
+
+	case *ExtendStatement:
+		node.Type, _ = Modify(node.Type, modifier).(*Identifier)
+		modifyIdentifiers(node.Traits, modifier)
+		for i, method := range node.Methods {
+			node.Methods[i], _ = Modify(method, modifier).(*FunctionLiteral)
+		}
+
+	case *TraitStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		modifyIdentifiers(node.Methods, modifier)
+
+	case *StructLiteral:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		modifyIdentifiers(node.Fields, modifier)
+		for i, value := range node.Values {
+			node.Values[i] = modifyExpression(value, modifier)
+		}
+
+	case *EnumStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		modifyIdentifiers(node.Variants, modifier)
+		for _, fields := range node.Fields {
+			modifyIdentifiers(fields, modifier)
+		}
+
+	case *LiteralPattern:
+		node.Value = modifyExpression(node.Value, modifier)
+
+	case *BindingPattern:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+
+	case *VariantPattern:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		for i, field := range node.Fields {
+			node.Fields[i] = modifyPattern(field, modifier)
+		}
+
+	case *ArrayPattern:
+		for i, el := range node.Elements {
+			node.Elements[i] = modifyPattern(el, modifier)
+		}
+
+	case *ArrayType:
+		node.Element = modifyType(node.Element, modifier)
+
+	case *FunctionType:
+		modifyTypes(node.Parameters, modifier)
+		node.Return = modifyType(node.Return, modifier)
+
+	case *UnionType:
+		modifyTypes(node.Types, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	modified, _ := Modify(exp, modifier).(Expression)
+	return modified
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) {
+	for i, exp := range exps {
+		exps[i] = modifyExpression(exp, modifier)
+	}
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) {
+	for i, stmt := range stmts {
+		stmts[i], _ = Modify(stmt, modifier).(Statement)
+	}
+}
+
+func modifyIdentifiers(idents []*Identifier, modifier ModifierFunc) {
+	for i, ident := range idents {
+		idents[i], _ = Modify(ident, modifier).(*Identifier)
+	}
+}
+
+func modifyType(typ TypeExpression, modifier ModifierFunc) TypeExpression {
+	if typ == nil {
+		return nil
+	}
+	modified, _ := Modify(typ, modifier).(TypeExpression)
+	return modified
+}
+
+func modifyPattern(pat Pattern, modifier ModifierFunc) Pattern {
+	if pat == nil {
+		return nil
+	}
+	modified, _ := Modify(pat, modifier).(Pattern)
+	return modified
+}
+
+func modifyTypes(types []TypeExpression, modifier ModifierFunc) {
+	for i, typ := range types {
+		types[i] = modifyType(typ, modifier)
+	}
+}
@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"morty/token"
+	"strings"
+)
+
+// Pattern is what a case of a match expression compares its subject
+// with, such as 0, Circle(r), [x, _] or a name binding any value.
+type Pattern interface {
+	Noder
+	patternNode()
+}
+
+// LiteralPattern fits values equal to an integer, string or boolean literal.
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression // an integer, possibly negated, string or boolean literal
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) ToString() string     { return lp.Value.ToString() }
+
+// BindingPattern fits any value, bound to Name in the case.
+type BindingPattern struct {
+	Token token.Token
+	Name  *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Token.Literal }
+func (bp *BindingPattern) ToString() string     { return bp.Name.ToString() }
+
+// WildcardPattern, _, fits any value.
+type WildcardPattern struct {
+	Token token.Token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) ToString() string     { return "_" }
+
+// VariantPattern fits the values of an enum variant whose fields fit Fields,
+// as in Rect(w, h) or Empty.
+type VariantPattern struct {
+	Token  token.Token
+	Name   *Identifier
+	Fields []Pattern
+}
+
+func (vp *VariantPattern) patternNode()         {}
+func (vp *VariantPattern) TokenLiteral() string { return vp.Token.Literal }
+func (vp *VariantPattern) ToString() string {
+	if len(vp.Fields) == 0 {
+		return vp.Name.ToString()
+	}
+	fields := []string{}
+	for _, f := range vp.Fields {
+		fields = append(fields, f.ToString())
+	}
+	return vp.Name.ToString() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// ArrayPattern fits the arrays of as many elements as Elements, each fitting its pattern.
+type ArrayPattern struct {
+	Token    token.Token // [ token
+	Elements []Pattern
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) ToString() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.ToString())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
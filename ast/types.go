@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"bytes"
+	"morty/token"
+	"strings"
+)
+
+// TypeExpression is a type annotation, such as int, [string] or fn(int) -> bool.
+type TypeExpression interface {
+	Noder
+	typeNode()
+}
+
+// NamedType is a type referred to by name, such as int or null.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) ToString() string     { return nt.Name }
+
+type ArrayType struct {
+	Token   token.Token // [ token
+	Element TypeExpression
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) ToString() string     { return "[" + at.Element.ToString() + "]" }
+
+type FunctionType struct {
+	Token      token.Token // fn token
+	Parameters []TypeExpression
+	Return     TypeExpression // nil when the return type is not given
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) ToString() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.ToString())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.Return != nil {
+		out.WriteString(" -> " + ft.Return.ToString())
+	}
+
+	return out.String()
+}
+
+// UnionType is a value of any of Types, as in int | null.
+type UnionType struct {
+	Token token.Token // the first | token
+	Types []TypeExpression
+}
+
+func (ut *UnionType) typeNode()            {}
+func (ut *UnionType) TokenLiteral() string { return ut.Token.Literal }
+func (ut *UnionType) ToString() string {
+	types := []string{}
+	for _, t := range ut.Types {
+		if ft, ok := t.(*FunctionType); ok && ft.Return != nil {
+			types = append(types, "("+t.ToString()+")") // or the return type would take the rest
+		} else {
+			types = append(types, t.ToString())
+		}
+	}
+	return strings.Join(types, " | ")
+}
@@ -0,0 +1,226 @@
+package ast
+
+// A Visitor's Visit method is called by Walk for every node it reaches. If
+// the returned visitor w is not nil, Walk visits the children of node with
+// w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Noder) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth first, in source order.
+// Optional children that are absent are skipped.
+func Walk(node Noder, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(node.Statements, v)
+
+	case *LetStatement:
+		Walk(node.Name, v)
+		walkType(node.Type, v)
+		walkExpression(node.Value, v)
+
+	case *ReturnStatement:
+		walkExpression(node.ReturnValue, v)
+
+	case *ExpressionStatement:
+		walkExpression(node.Expression, v)
+
+	case *ThrowStatement:
+		walkExpression(node.Value, v)
+
+	case *BlockStatement:
+		walkStatements(node.Statements, v)
+
+	case *PrefixExpression:
+		walkExpression(node.Right, v)
+
+	case *InfixExpression:
+		walkExpression(node.Left, v)
+		walkExpression(node.Right, v)
+
+	case *PostfixExpression:
+		walkExpression(node.Left, v)
+
+	case *IfExpression:
+		walkExpression(node.Condition, v)
+		Walk(node.Concequence, v)
+		if node.Alternative != nil {
+			Walk(node.Alternative, v)
+		}
+
+	case *MatchExpression:
+		walkExpression(node.Subject, v)
+		for _, c := range node.Cases {
+			Walk(c, v)
+		}
+
+	case *MatchCase:
+		walkPattern(node.Pattern, v)
+		walkExpression(node.Guard, v)
+		Walk(node.Body, v)
+
+	case *FunctionLiteral:
+		if node.Name != nil {
+			Walk(node.Name, v)
+		}
+		for i, param := range node.Parameters {
+			Walk(param, v)
+			walkType(node.ParameterType(i), v)
+		}
+		walkType(node.ReturnType, v)
+		Walk(node.Body, v)
+
+	case *MacroLiteral:
+		walkIdentifiers(node.Parameters, v)
+		Walk(node.Body, v)
+
+	case *CallExpression:
+		walkExpression(node.Function, v)
+		walkExpressions(node.Arguments, v)
+
+	case *ArrayLiteral:
+		walkExpressions(node.Elements, v)
+
+	case *MemberExpression:
+		walkExpression(node.Object, v)
+		Walk(node.Property, v)
+
+	case *AssignExpression:
+		walkExpression(node.Target, v)
+		walkExpression(node.Value, v)
+
+	case *TryExpression:
+		Walk(node.Block, v)
+		if node.Catch != nil {
+			Walk(node.CatchParam, v)
+			Walk(node.Catch, v)
+		}
+		if node.Finally != nil {
+			Walk(node.Finally, v)
+		}
+
+	case *StructStatement:
+		Walk(node.Name, v)
+		walkIdentifiers(node.Fields, v)
+
+	case *ExtendStatement:
+		Walk(node.Type, v)
+		walkIdentifiers(node.Traits, v)
+		for _, method := range node.Methods {
+			Walk(method, v)
+		}
+
+	case *TraitStatement:
+		Walk(node.Name, v)
+		walkIdentifiers(node.Methods, v)
+
+	case *StructLiteral:
+		Walk(node.Name, v)
+		for i, field := range node.Fields {
+			Walk(field, v)
+			walkExpression(node.Values[i], v)
+		}
+
+	case *EnumStatement:
+		Walk(node.Name, v)
+		for i, variant := range node.Variants {
+			Walk(variant, v)
+			walkIdentifiers(node.Fields[i], v)
+		}
+
+	case *ArrayType:
+		walkType(node.Element, v)
+
+	case *FunctionType:
+		for _, param := range node.Parameters {
+			walkType(param, v)
+		}
+		walkType(node.Return, v)
+
+	case *UnionType:
+		for _, typ := range node.Types {
+			walkType(typ, v)
+		}
+
+	case *LiteralPattern:
+		walkExpression(node.Value, v)
+
+	case *BindingPattern:
+		Walk(node.Name, v)
+
+	case *VariantPattern:
+		Walk(node.Name, v)
+		for _, field := range node.Fields {
+			walkPattern(field, v)
+		}
+
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			walkPattern(el, v)
+		}
+
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral, *NamedType, *WildcardPattern:
+		// leaves
+	}
+
+	v.Visit(nil)
+}
+
+// walkExpression skips expressions left nil by parse errors.
+func walkExpression(exp Expression, v Visitor) {
+	if exp != nil {
+		Walk(exp, v)
+	}
+}
+
+// walkType skips absent annotations.
+func walkType(typ TypeExpression, v Visitor) {
+	if typ != nil {
+		Walk(typ, v)
+	}
+}
+
+// walkPattern skips patterns left nil by parse errors.
+func walkPattern(pat Pattern, v Visitor) {
+	if pat != nil {
+		Walk(pat, v)
+	}
+}
+
+func walkExpressions(exps []Expression, v Visitor) {
+	for _, exp := range exps {
+		walkExpression(exp, v)
+	}
+}
+
+func walkStatements(stmts []Statement, v Visitor) {
+	for _, stmt := range stmts {
+		Walk(stmt, v)
+	}
+}
+
+func walkIdentifiers(idents []*Identifier, v Visitor) {
+	for _, ident := range idents {
+		Walk(ident, v)
+	}
+}
+
+type inspector func(Noder) bool
+
+func (f inspector) Visit(node Noder) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks node calling f for every node, skipping the children of
+// the nodes for which f returns false. After the children of a node f is
+// called with nil.
+func Inspect(node Noder, f func(Noder) bool) {
+	Walk(node, inspector(f))
+}
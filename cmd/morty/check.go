@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"morty/diagnostic"
+	"morty/lexer"
+	"morty/parser"
+	"morty/types"
+	"os"
+)
+
+// runCheck implements `morty check [--json] files...`. It returns 1 when a
+// file does not parse or has type errors, and 0 otherwise.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "report errors as JSON, one object per file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: morty check [flags] files...\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "morty check: %s\n", err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			_, diags = types.Check(program)
+		}
+		if len(diags) > 0 {
+			status = 1
+		}
+
+		if *asJSON {
+			diagnostic.WriteJSON(os.Stdout, name, diags)
+		} else {
+			diagnostic.Render(os.Stdout, name, string(src), diags)
+		}
+	}
+	return status
+}
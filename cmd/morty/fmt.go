@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"morty/format"
+	"os"
+)
+
+// runFmt implements `morty fmt [--check] [-w] [files...]`, formatting
+// standard input when no files are given. It returns the exit status.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted and exit with status 1 if there are any")
+	write := flags.Bool("w", false, "write the result back to the files instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: morty fmt [--check] [-w] [files...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "morty fmt: cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "morty fmt: %s\n", err)
+			return 1
+		}
+		return formatFile("<stdin>", src, *check, false)
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "morty fmt: %s\n", err)
+			status = 1
+			continue
+		}
+		if s := formatFile(name, src, *check, *write); s > status {
+			status = s
+		}
+	}
+	return status
+}
+
+func formatFile(name string, src []byte, check, write bool) int {
+	formatted, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return 1
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(src, formatted) {
+			fmt.Println(name)
+			return 1
+		}
+	case write:
+		if bytes.Equal(src, formatted) {
+			return 0
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "morty fmt: %s\n", err)
+			return 1
+		}
+		if err := os.WriteFile(name, formatted, info.Mode().Perm()); err != nil {
+			fmt.Fprintf(os.Stderr, "morty fmt: %s\n", err)
+			return 1
+		}
+	default:
+		os.Stdout.Write(formatted)
+	}
+	return 0
+}
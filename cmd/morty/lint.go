@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"morty/diagnostic"
+	"morty/lexer"
+	"morty/lint"
+	"morty/parser"
+	"os"
+	"strings"
+)
+
+// runLint implements `morty lint [flags] files...`. It returns 1 when a file
+// does not parse or a rule set to error finds something, and 0 otherwise.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "read rule levels from this JSON file (default "+lint.ConfigFile+" if it exists)")
+	disable := flags.String("disable", "", "comma separated rules to turn off")
+	enable := flags.String("enable", "", "comma separated rules to report as warnings")
+	asErrors := flags.String("error", "", "comma separated rules to report as errors")
+	asJSON := flags.Bool("json", false, "report findings as JSON, one object per file")
+	list := flags.Bool("rules", false, "list the rules and exit")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: morty lint [flags] files...\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-20s %s %s\n", r.Name, r.Code, r.Doc)
+		}
+		return 0
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	config, err := lintConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "morty lint: %s\n", err)
+		return 2
+	}
+	for level, names := range map[string]string{lint.OFF: *disable, lint.WARNING: *enable, lint.ERROR: *asErrors} {
+		if names != "" {
+			config.Set(level, strings.Split(names, ",")...)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "morty lint: %s\n", err)
+		return 2
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "morty lint: %s\n", err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			diags = lint.Lint(program, config)
+		}
+
+		for _, d := range diags {
+			if d.Severity == diagnostic.Error {
+				status = 1
+			}
+		}
+		if *asJSON {
+			diagnostic.WriteJSON(os.Stdout, name, diags)
+		} else {
+			diagnostic.Render(os.Stdout, name, string(src), diags)
+		}
+	}
+	return status
+}
+
+func lintConfig(path string) (lint.Config, error) {
+	if path != "" {
+		return lint.LoadConfig(path)
+	}
+	config, err := lint.LoadConfig(lint.ConfigFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lint.Config{}, nil
+	}
+	return config, err
+}
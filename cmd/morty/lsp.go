@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"morty/lsp"
+	"os"
+)
+
+// runLSP implements `morty lsp`, serving an editor over standard input and output.
+func runLSP(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: morty lsp")
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "morty lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
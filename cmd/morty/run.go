@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"morty"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"os"
+	"os/signal"
+)
+
+// runRun implements `morty run [--json] file`, running a whole file and
+// reporting its errors on standard error. It returns the exit status.
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "report errors as JSON, one object per file")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: morty run [--json] file\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	src, err := os.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "morty run: %s\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	interp := morty.New(morty.WithCapabilities(evaluator.AllCapabilities...))
+	_, err = interp.Run(ctx, string(src))
+
+	var diags []diagnostic.Diagnostic
+	var parseErr *morty.ParseError
+	var runtimeErr *morty.RuntimeError
+	switch {
+	case errors.As(err, &parseErr):
+		diags = parseErr.Diagnostics
+	case errors.As(err, &runtimeErr):
+		diags = []diagnostic.Diagnostic{runtimeErr.Diagnostic}
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "morty run: %s\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		if err := diagnostic.WriteJSON(os.Stderr, name, diags); err != nil {
+			fmt.Fprintf(os.Stderr, "morty run: %s\n", err)
+			return 1
+		}
+	} else {
+		diagnostic.Render(os.Stderr, name, string(src), diags)
+	}
+
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,395 @@
+// Package cst is a lossless concrete syntax tree over Morty source. Every
+// byte of the source belongs to a token or to the trivia around one, so a
+// tree prints back to its exact source, and refactoring tools can edit the
+// source at the byte offsets of the nodes they change, leaving the rest
+// untouched.
+package cst
+
+import (
+	"morty/ast"
+	"morty/lexer"
+	"morty/parser"
+	"morty/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota // spaces, tabs and carriage returns
+	Newline
+	Comment
+	Skipped // source the lexer stopped before, such as what follows a NUL byte
+)
+
+// Trivia is source text between tokens.
+type Trivia struct {
+	Kind   TriviaKind
+	Text   string
+	Offset int
+}
+
+// Token is a token with the trivia around it. Trailing trivia runs up to the
+// end of the line of the token; the newline and what follows it are leading
+// trivia of the next token.
+type Token struct {
+	token.Token
+	Raw      string // the token as written, with the quotes of a string
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Span returns the byte offsets of the token, without its trivia.
+func (t *Token) Span() (start, end int) {
+	return t.Pos.Offset, t.Pos.Offset + len(t.Raw)
+}
+
+func (t *Token) FullText() string {
+	var out strings.Builder
+	writeTrivia(&out, t.Leading)
+	out.WriteString(t.Raw)
+	writeTrivia(&out, t.Trailing)
+	return out.String()
+}
+
+// Element is a *Node or a *Token.
+type Element interface {
+	Span() (start, end int)
+	FullText() string
+}
+
+// Node is the part of the source an AST node was parsed from. Its children
+// are the nodes of the AST children of Node.AST and the tokens, such as
+// parentheses, commas and semicolons, that belong to it directly.
+type Node struct {
+	AST      ast.Noder
+	Children []Element
+	lo, hi   int // indexes of the first and last token of the node
+	tokens   []*Token
+}
+
+// Kind returns the name of the AST node type, such as "LetStatement".
+func (n *Node) Kind() string {
+	return reflect.TypeOf(n.AST).Elem().Name()
+}
+
+// Tokens returns the tokens of the node in source order, none for nodes
+// made by a tool or standing for a parse error.
+func (n *Node) Tokens() []*Token {
+	if n.hi < n.lo {
+		return nil
+	}
+	return n.tokens[n.lo : n.hi+1]
+}
+
+// Span returns the byte offsets of the node, without the leading trivia of
+// its first token and the trailing trivia of its last. It is empty for a
+// node without tokens.
+func (n *Node) Span() (start, end int) {
+	tokens := n.Tokens()
+	if len(tokens) == 0 {
+		return 0, 0
+	}
+	start, _ = tokens[0].Span()
+	_, end = tokens[len(tokens)-1].Span()
+	return start, end
+}
+
+// Text returns the source of the node, without its outer trivia.
+func (n *Node) Text() string {
+	tokens := n.Tokens()
+	var out strings.Builder
+	for i, tok := range tokens {
+		if i > 0 {
+			writeTrivia(&out, tok.Leading)
+		}
+		out.WriteString(tok.Raw)
+		if i < len(tokens)-1 {
+			writeTrivia(&out, tok.Trailing)
+		}
+	}
+	return out.String()
+}
+
+func (n *Node) FullText() string {
+	var out strings.Builder
+	for _, tok := range n.Tokens() {
+		out.WriteString(tok.FullText())
+	}
+	return out.String()
+}
+
+// Tree is the concrete syntax tree of a source file.
+type Tree struct {
+	Root   *Node    // the node of the *ast.Program
+	Tokens []*Token // every token, ending with EOF
+	Errors []string // the parser errors; the tree still covers all the source
+	nodes  map[ast.Noder]*Node
+}
+
+// String returns the source the tree was parsed from.
+func (t *Tree) String() string {
+	return t.Root.FullText()
+}
+
+// AST returns the program the parser built from the tokens of the tree.
+func (t *Tree) AST() *ast.Program {
+	return t.Root.AST.(*ast.Program)
+}
+
+// Find returns the node of an AST node of the tree.
+func (t *Tree) Find(node ast.Noder) (*Node, bool) {
+	n, ok := t.nodes[node]
+	return n, ok
+}
+
+// Parse lexes src keeping its trivia, parses the tokens and builds the tree
+// over the resulting AST.
+func Parse(src string) *Tree {
+	l := lexer.New(src)
+	var toks []token.Token
+	for {
+		tok := l.NextToken()
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	tree := &Tree{Tokens: withTrivia(src, toks), nodes: map[ast.Noder]*Node{}}
+
+	p := parser.NewFromTokenizer(&replay{tokens: toks, comments: l.Comments()})
+	program := p.ParseProgram()
+	tree.Errors = p.Errors()
+
+	b := newBuilder(tree)
+	tree.Root = b.build(program)
+	return tree
+}
+
+// replay feeds already lexed tokens to the parser.
+type replay struct {
+	tokens   []token.Token
+	comments []token.Token
+	next     int
+}
+
+func (r *replay) NextToken() token.Token {
+	tok := r.tokens[r.next]
+	if r.next < len(r.tokens)-1 {
+		r.next++
+	}
+	return tok
+}
+
+func (r *replay) Comments() []token.Token {
+	return r.comments
+}
+
+func withTrivia(src string, toks []token.Token) []*Token {
+	tokens := make([]*Token, len(toks))
+	prevEnd := 0
+	for i, tok := range toks {
+		t := &Token{Token: tok, Raw: src[tok.Pos.Offset:rawEnd(src, tok)]}
+		tokens[i] = t
+
+		gap := splitTrivia(src[prevEnd:tok.Pos.Offset], prevEnd)
+		if i == 0 {
+			t.Leading = gap
+		} else {
+			split := len(gap)
+			for j, trivia := range gap {
+				if trivia.Kind == Newline {
+					split = j
+					break
+				}
+			}
+			tokens[i-1].Trailing = gap[:split]
+			t.Leading = gap[split:]
+		}
+		_, prevEnd = t.Span()
+	}
+
+	if prevEnd < len(src) {
+		last := tokens[len(tokens)-1]
+		last.Trailing = append(last.Trailing, Trivia{Kind: Skipped, Text: src[prevEnd:], Offset: prevEnd})
+	}
+	return tokens
+}
+
+func rawEnd(src string, tok token.Token) int {
+	start := tok.Pos.Offset
+	switch tok.Type {
+	case token.EOF:
+		return start
+	case token.ILLEGAL:
+		return start + 1
+	case token.STRING:
+		end := start + 1 + len(tok.Literal)
+		if end < len(src) && src[end] == '"' {
+			end++
+		}
+		return end
+	default:
+		return start + len(tok.Literal)
+	}
+}
+
+func splitTrivia(text string, offset int) []Trivia {
+	var trivia []Trivia
+	for len(text) > 0 {
+		var size int
+		var kind TriviaKind
+		switch {
+		case text[0] == '\n':
+			kind, size = Newline, 1
+		case strings.HasPrefix(text, "//"):
+			kind, size = Comment, strings.IndexByte(text, '\n')
+			if size < 0 {
+				size = len(text)
+			}
+		case text[0] == ' ' || text[0] == '\t' || text[0] == '\r':
+			kind, size = Whitespace, len(text)-len(strings.TrimLeft(text, " \t\r"))
+		default:
+			kind, size = Skipped, 1
+		}
+		trivia = append(trivia, Trivia{Kind: kind, Text: text[:size], Offset: offset})
+		text, offset = text[size:], offset+size
+	}
+	return trivia
+}
+
+func writeTrivia(out *strings.Builder, trivia []Trivia) {
+	for _, t := range trivia {
+		out.WriteString(t.Text)
+	}
+}
+
+type builder struct {
+	tree    *Tree
+	index   map[int]int // token index by offset
+	matches []int       // index of the matching bracket, or -1
+}
+
+func newBuilder(tree *Tree) *builder {
+	b := &builder{tree: tree, index: map[int]int{}, matches: make([]int, len(tree.Tokens))}
+
+	var open []int
+	for i, tok := range tree.Tokens {
+		b.index[tok.Pos.Offset] = i
+		b.matches[i] = -1
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, i)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) > 0 {
+				opener := open[len(open)-1]
+				open = open[:len(open)-1]
+				b.matches[opener], b.matches[i] = i, opener
+			}
+		}
+	}
+	return b
+}
+
+func (b *builder) build(node ast.Noder) *Node {
+	n := &Node{AST: node, lo: len(b.tree.Tokens), hi: -1, tokens: b.tree.Tokens}
+	b.tree.nodes[node] = n
+
+	for _, tok := range ownTokens(node) {
+		idx, ok := b.index[tok.Pos.Offset]
+		if !ok || b.tree.Tokens[idx].Type != tok.Type {
+			continue // made by a tool, or a placeholder of a parse error
+		}
+		n.lo, n.hi = min(n.lo, idx), max(n.hi, idx, b.matches[idx])
+	}
+
+	var children []*Node
+	for _, child := range directChildren(node) {
+		c := b.build(child)
+		if c.hi < 0 {
+			continue
+		}
+		children = append(children, c)
+		n.lo, n.hi = min(n.lo, c.lo), max(n.hi, c.hi)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].lo < children[j].lo })
+
+	if _, ok := node.(*ast.Program); ok {
+		n.lo, n.hi = 0, len(b.tree.Tokens)-1
+	}
+	if n.hi < 0 {
+		return n
+	}
+	b.extend(n)
+
+	for i, next := n.lo, 0; i <= n.hi; {
+		for next < len(children) && children[next].lo < i {
+			next++ // overlaps a sibling, which only happens after parse errors
+		}
+		if next < len(children) && children[next].lo == i {
+			n.Children = append(n.Children, children[next])
+			i = children[next].hi + 1
+			next++
+			continue
+		}
+		n.Children = append(n.Children, b.tree.Tokens[i])
+		i++
+	}
+	return n
+}
+
+// extend widens the node over the parentheses grouping its first or last
+// operand and over the semicolons ending a statement.
+func (b *builder) extend(n *Node) {
+	tokens := b.tree.Tokens
+	for changed := true; changed; {
+		changed = false
+		if n.lo > 0 && tokens[n.lo-1].Type == token.LPAREN && b.matches[n.lo-1] > n.lo && b.matches[n.lo-1] <= n.hi {
+			n.lo--
+			changed = true
+		}
+		if n.hi+1 < len(tokens) && tokens[n.hi+1].Type == token.RPAREN && b.matches[n.hi+1] >= n.lo && b.matches[n.hi+1] < n.hi {
+			n.hi++
+			changed = true
+		}
+	}
+
+	switch n.AST.(type) {
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.ThrowStatement, *ast.StructStatement,
+		*ast.TraitStatement, *ast.ExtendStatement, *ast.EnumStatement:
+		if n.hi+1 < len(tokens) && tokens[n.hi+1].Type == token.SEMICOLON {
+			n.hi++
+		}
+	case *ast.ExpressionStatement:
+		for n.hi+1 < len(tokens) && tokens[n.hi+1].Type == token.SEMICOLON {
+			n.hi++
+		}
+	}
+}
+
+// ownTokens returns the tokens an AST node keeps, as opposed to its children.
+func ownTokens(node ast.Noder) []token.Token {
+	var tokens []token.Token
+	v := reflect.ValueOf(node).Elem()
+	for _, name := range []string{"Token", "Rbrace"} {
+		if field := v.FieldByName(name); field.IsValid() {
+			tokens = append(tokens, field.Interface().(token.Token))
+		}
+	}
+	return tokens
+}
+
+func directChildren(node ast.Noder) []ast.Noder {
+	var children []ast.Noder
+	ast.Inspect(node, func(n ast.Noder) bool {
+		if n == nil || n == node {
+			return n == node
+		}
+		children = append(children, n)
+		return false
+	})
+	return children
+}
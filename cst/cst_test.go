@@ -0,0 +1,188 @@
+package cst
+
+import (
+	"morty/ast"
+	"morty/lexer"
+	"morty/parser"
+	"strings"
+	"testing"
+)
+
+var sources = []string{
+	"",
+	"   \n\n",
+	"let x = 5;",
+	"// header\n\nlet   add = fn(a,b) {\n\t// sum\n\treturn a+b; // done\n};\n\nadd(1,\n  2)\n",
+	"(a + b) * -(c)",
+	"if ((x)) { 1 } else { [1, \"two\", f(3)?] }",
+	"try { throw \"boom\" } catch (e) { e.message } finally { p.x = 1 }",
+	"let m = macro(a) { quote(unquote(a)) };;;",
+	"\"unterminated",
+	"let = 5; @ ) }",
+	"x\x00 after a NUL",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, src := range sources {
+		tree := Parse(src)
+		if got := tree.String(); got != src {
+			t.Errorf("tree of %q prints %q", src, got)
+		}
+
+		var tokens []*Token
+		collectTokens(tree.Root, &tokens)
+		if len(tokens) != len(tree.Tokens) {
+			t.Errorf("tree of %q holds %d tokens, want %d", src, len(tokens), len(tree.Tokens))
+			continue
+		}
+		for i := range tokens {
+			if tokens[i] != tree.Tokens[i] {
+				t.Errorf("tree of %q holds token %q at %d, want %q", src, tokens[i].Raw, i, tree.Tokens[i].Raw)
+			}
+		}
+	}
+}
+
+func collectTokens(n *Node, tokens *[]*Token) {
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *Node:
+			collectTokens(child, tokens)
+		case *Token:
+			*tokens = append(*tokens, child)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	tree := Parse("let x = 5; // five\n// next\n\nlet y = 1;")
+
+	semicolon := tree.Tokens[4]
+	if semicolon.Raw != ";" || len(semicolon.Trailing) != 2 || semicolon.Trailing[1].Text != "// five" {
+		t.Fatalf("wrong trailing trivia of %q: %+v", semicolon.Raw, semicolon.Trailing)
+	}
+
+	let := tree.Tokens[5]
+	kinds := []TriviaKind{Newline, Comment, Newline, Newline}
+	if len(let.Leading) != len(kinds) {
+		t.Fatalf("wrong leading trivia of second let: %+v", let.Leading)
+	}
+	for i, kind := range kinds {
+		if let.Leading[i].Kind != kind {
+			t.Errorf("leading trivia %d has kind %d, want %d", i, let.Leading[i].Kind, kind)
+		}
+	}
+	if let.Leading[1].Offset != 19 {
+		t.Errorf("comment at offset %d, want 19", let.Leading[1].Offset)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	src := "let r = (a + b) * f(1, 2);\nif (r > 1) { r }\nstruct P { x };\nP{x: 1}\ntrait S { f };\nextend P: S { fn f(self) { 1 } }\nenum E { A(v), B };\nmatch (r) { case A([v, _]) if v { v } case _ { 0 } }"
+	tree := Parse(src)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("parser errors: %q", tree.Errors)
+	}
+
+	tests := []struct {
+		kind string
+		text string
+	}{
+		{"Program", src},
+		{"LetStatement", "let r = (a + b) * f(1, 2);"},
+		{"InfixExpression", "(a + b) * f(1, 2)"},
+		{"InfixExpression", "a + b"},
+		{"CallExpression", "f(1, 2)"},
+		{"ExpressionStatement", "if (r > 1) { r }"},
+		{"IfExpression", "if (r > 1) { r }"},
+		{"BlockStatement", "{ r }"},
+		{"StructStatement", "struct P { x };"},
+		{"StructLiteral", "P{x: 1}"},
+		{"TraitStatement", "trait S { f };"},
+		{"ExtendStatement", "extend P: S { fn f(self) { 1 } }"},
+		{"FunctionLiteral", "fn f(self) { 1 }"},
+		{"EnumStatement", "enum E { A(v), B };"},
+		{"MatchExpression", "match (r) { case A([v, _]) if v { v } case _ { 0 } }"},
+		{"MatchCase", "case A([v, _]) if v { v }"},
+		{"VariantPattern", "A([v, _])"},
+		{"ArrayPattern", "[v, _]"},
+		{"WildcardPattern", "_"},
+	}
+
+	var nodes []*Node
+	ast.Inspect(tree.AST(), func(n ast.Noder) bool {
+		if n != nil {
+			node, ok := tree.Find(n)
+			if !ok {
+				t.Fatalf("no node for %T", n)
+			}
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+
+	for _, tt := range tests {
+		found := false
+		for _, n := range nodes {
+			if n.Kind() == tt.kind && n.Text() == tt.text {
+				found = true
+				start, end := n.Span()
+				if src[start:end] != tt.text {
+					t.Errorf("span of %s %q is %q", tt.kind, tt.text, src[start:end])
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no %s node with text %q", tt.kind, tt.text)
+		}
+	}
+}
+
+func TestDerivedAST(t *testing.T) {
+	for _, src := range sources {
+		expected := parser.New(lexer.New(src)).ParseProgram()
+		if got := Parse(src).AST(); got.ToString() != expected.ToString() {
+			t.Errorf("AST of %q is %q, want %q", src, got.ToString(), expected.ToString())
+		}
+	}
+}
+
+// TestEmptyNode checks that a node without tokens, as built for AST nodes
+// made by a tool, has an empty span and text.
+func TestEmptyNode(t *testing.T) {
+	tree := Parse("let x = 5;")
+	node := &Node{AST: &ast.Identifier{Value: "y"}, lo: len(tree.Tokens), hi: -1, tokens: tree.Tokens}
+
+	if len(node.Tokens()) != 0 {
+		t.Errorf("expected no tokens, got=%d", len(node.Tokens()))
+	}
+	if start, end := node.Span(); start != 0 || end != 0 {
+		t.Errorf("wrong span, got=%d-%d", start, end)
+	}
+	if node.Text() != "" || node.FullText() != "" {
+		t.Errorf("wrong text, got=%q and %q", node.Text(), node.FullText())
+	}
+}
+
+// TestMinimalEdit renames a binding by splicing the spans of its identifiers.
+func TestMinimalEdit(t *testing.T) {
+	src := "// keep me\nlet  total = 1;   // and me\nputs(total,total)\n"
+	tree := Parse(src)
+
+	var edited strings.Builder
+	last := 0
+	for _, tok := range tree.Tokens {
+		if tok.Raw == "total" {
+			start, end := tok.Span()
+			edited.WriteString(src[last:start])
+			edited.WriteString("sum")
+			last = end
+		}
+	}
+	edited.WriteString(src[last:])
+
+	expected := "// keep me\nlet  sum = 1;   // and me\nputs(sum,sum)\n"
+	if edited.String() != expected {
+		t.Errorf("wrong edit, got=%q", edited.String())
+	}
+}
@@ -0,0 +1,238 @@
+// Package diagnostic describes problems found in Morty source, such as syntax and
+// runtime errors, and renders them for terminals and for tools.
+package diagnostic
+
+import (
+	"fmt"
+	"morty/ast"
+	"morty/object"
+	"morty/token"
+	"reflect"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+var severityNames = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string { return severityNames[s] }
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Code identifies a kind of diagnostic. Codes are stable, so tools may match on them.
+type Code string
+
+// Syntax errors
+const (
+	IllegalCharacter    Code = "E0101"
+	UnexpectedToken     Code = "E0102"
+	InvalidInteger      Code = "E0103"
+	InvalidAssignTarget Code = "E0104"
+	MissingHandler      Code = "E0105"
+	UnclosedDelimiter   Code = "E0106"
+)
+
+// Warnings of static checks
+const (
+	UndefinedName     Code = "W0101"
+	UnusedBinding     Code = "W0102"
+	ShadowedBinding   Code = "W0103"
+	UnreachableCode   Code = "W0104"
+	WrongArgCount     Code = "W0105"
+	ConstantCondition Code = "W0106"
+	LiteralMismatch   Code = "W0107"
+)
+
+// Runtime errors, one per error kind
+const (
+	UncaughtError      Code = "E0201"
+	TypeError          Code = "E0202"
+	NameError          Code = "E0203"
+	ArityError         Code = "E0204"
+	ZeroDivision       Code = "E0205"
+	PermissionDenied   Code = "E0206"
+	NonExhaustiveMatch Code = "E0207"
+	Cancelled          Code = "E0301"
+	StepLimit          Code = "E0302"
+	AllocationLimit    Code = "E0303"
+	OutputLimit        Code = "E0304"
+	UnknownRuntimeKind Code = "E0299"
+)
+
+// Type errors, found by the type checker
+const (
+	UnknownType      Code = "E0401"
+	MismatchedTypes  Code = "E0402"
+	NullableOperand  Code = "E0403"
+	NotCallable      Code = "E0404"
+	ArgumentCount    Code = "E0405"
+	IncompatibleType Code = "E0406"
+	UnknownName      Code = "E0407"
+)
+
+var titles = map[Code]string{
+	IllegalCharacter:    "illegal character",
+	UnexpectedToken:     "unexpected token",
+	InvalidInteger:      "invalid integer literal",
+	InvalidAssignTarget: "invalid assignment target",
+	MissingHandler:      "try without catch or finally",
+	UnclosedDelimiter:   "unclosed delimiter",
+
+	UndefinedName:     "undefined name",
+	UnusedBinding:     "unused binding",
+	ShadowedBinding:   "shadowed binding",
+	UnreachableCode:   "unreachable code",
+	WrongArgCount:     "wrong number of arguments",
+	ConstantCondition: "constant condition",
+	LiteralMismatch:   "operands of mismatched types",
+
+	UncaughtError:      "uncaught error",
+	TypeError:          "type error",
+	NameError:          "name error",
+	ArityError:         "wrong number of arguments",
+	ZeroDivision:       "division by zero",
+	PermissionDenied:   "permission denied",
+	NonExhaustiveMatch: "non-exhaustive match",
+	Cancelled:          "execution cancelled",
+	StepLimit:          "step limit exceeded",
+	AllocationLimit:    "allocation limit exceeded",
+	OutputLimit:        "output limit exceeded",
+	UnknownRuntimeKind: "runtime error",
+
+	UnknownType:      "unknown type",
+	MismatchedTypes:  "mismatched types",
+	NullableOperand:  "possibly null value",
+	NotCallable:      "call of a non-function",
+	ArgumentCount:    "wrong number of arguments",
+	IncompatibleType: "incompatible type",
+	UnknownName:      "undefined name",
+}
+
+// Title is a short description of the code, as in "E0102 unexpected token".
+func (c Code) Title() string { return titles[c] }
+
+var kindCodes = map[string]Code{
+	object.ERROR:                  UncaughtError,
+	object.TYPE_ERROR:             TypeError,
+	object.NAME_ERROR:             NameError,
+	object.ARITY_ERROR:            ArityError,
+	object.ZERO_DIVISION_ERROR:    ZeroDivision,
+	object.PERMISSION_ERROR:       PermissionDenied,
+	object.MATCH_ERROR:            NonExhaustiveMatch,
+	object.CANCELLED_ERROR:        Cancelled,
+	object.STEP_LIMIT_ERROR:       StepLimit,
+	object.ALLOCATION_LIMIT_ERROR: AllocationLimit,
+	object.OUTPUT_LIMIT_ERROR:     OutputLimit,
+}
+
+// Span is the source between Start and End, End excluded.
+// The zero Span stands for no place in the source.
+type Span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+func (s Span) IsZero() bool { return s.Start.Line == 0 }
+
+// Label points at a span, with an optional message about it.
+type Label struct {
+	Span    Span   `json:"span"`
+	Message string `json:"message,omitempty"`
+}
+
+// Suggestion is a fix: replacing the span with Replacement.
+type Suggestion struct {
+	Message     string `json:"message"`
+	Span        Span   `json:"span"`
+	Replacement string `json:"replacement"`
+}
+
+type Diagnostic struct {
+	Severity    Severity     `json:"severity"`
+	Code        Code         `json:"code"`
+	Message     string       `json:"message"`
+	Primary     Label        `json:"primary"`
+	Secondary   []Label      `json:"secondary,omitempty"`
+	Notes       []string     `json:"notes,omitempty"`
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+// Errorf returns an error diagnostic pointing at span.
+func Errorf(code Code, span Span, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Error, Code: code, Message: fmt.Sprintf(format, a...), Primary: Label{Span: span}}
+}
+
+// FromError describes a runtime error, pointing at the node that failed if it is known.
+func FromError(err *object.Error) Diagnostic {
+	code, ok := kindCodes[err.Kind]
+	if !ok {
+		code = UnknownRuntimeKind
+	}
+	d := Errorf(code, Span{}, "%s", err.Message)
+	if err.Node != nil {
+		d.Primary = Label{Span: NodeSpan(err.Node), Message: err.Kind}
+	}
+	if !err.Catchable() {
+		d.Notes = append(d.Notes, "this error cannot be caught by try")
+	}
+	return d
+}
+
+// TokenSpan returns the span of the source text of tok.
+func TokenSpan(tok token.Token) Span {
+	size := len(tok.Literal)
+	switch tok.Type {
+	case token.STRING:
+		size += 2 // the quotes
+	case token.EOF:
+		size = 0
+	}
+	end := tok.Pos
+	end.Offset += size
+	end.Column += size
+	return Span{Start: tok.Pos, End: end}
+}
+
+// NodeSpan returns the span from the first to the last token of node found in the AST.
+func NodeSpan(node ast.Noder) Span {
+	var span Span
+	found := false
+	add := func(tok token.Token) {
+		if tok.Pos.Line == 0 {
+			return
+		}
+		ts := TokenSpan(tok)
+		if !found || ts.Start.Offset < span.Start.Offset {
+			span.Start = ts.Start
+		}
+		if !found || ts.End.Offset > span.End.Offset {
+			span.End = ts.End
+		}
+		found = true
+	}
+
+	ast.Inspect(node, func(n ast.Noder) bool {
+		if n == nil {
+			return false
+		}
+		if field := reflect.ValueOf(n).Elem().FieldByName("Token"); field.IsValid() {
+			add(field.Interface().(token.Token))
+		}
+		if field := reflect.ValueOf(n).Elem().FieldByName("Rbrace"); field.IsValid() {
+			add(field.Interface().(token.Token)) // blocks and struct bodies end there
+		}
+		return true
+	})
+	return span
+}
@@ -0,0 +1,192 @@
+package diagnostic_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/lexer"
+	"morty/object"
+	"morty/parser"
+	"morty/token"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	src := "let x = 1;\nif (x {\n\tputs(x);\n}\n"
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	diagnostic.Render(&out, "main.morty", src, p.Diagnostics())
+
+	expected := `error[E0102]: expected next token to be ), got { instead
+ --> main.morty:2:7
+  |
+2 | if (x {
+  |       ^ expected )
+  = help: insert ) after x
+
+`
+	if out.String() != expected {
+		t.Errorf("wrong rendering. want=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestRenderSecondary(t *testing.T) {
+	src := "let f = fn() {\n\tlet a = 1;\n"
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	diagnostic.Render(&out, "main.morty", src, p.Diagnostics())
+
+	expected := "error[E0106]: expected next token to be }, got EOF instead\n" +
+		" --> main.morty:3:1\n" +
+		"  |\n" +
+		"1 | let f = fn() {\n" +
+		"  |              - unclosed { opened here\n" +
+		"3 | \n" +
+		"  | ^ expected }\n" +
+		"\n"
+	if out.String() != expected {
+		t.Errorf("wrong rendering. want=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     diagnostic.Code
+		start    token.Position
+		end      token.Position
+		rendered string
+	}{
+		{
+			"let f = fn(a) { a + true };\nf(1);",
+			diagnostic.TypeError,
+			token.Position{Offset: 16, Line: 1, Column: 17},
+			token.Position{Offset: 24, Line: 1, Column: 25},
+			"error[E0202]: type mismatch: INTEGER + BOOLEAN\n" +
+				" --> main.morty:1:17\n" +
+				"  |\n" +
+				"1 | let f = fn(a) { a + true };\n" +
+				"  |                 ^^^^^^^^ TypeError\n" +
+				"\n",
+		},
+		{
+			"let a = 1;\n  missing(a)",
+			diagnostic.NameError,
+			token.Position{Offset: 13, Line: 2, Column: 3},
+			token.Position{Offset: 20, Line: 2, Column: 10},
+			"error[E0203]: identifier not found: missing\n" +
+				" --> main.morty:2:3\n" +
+				"  |\n" +
+				"2 |   missing(a)\n" +
+				"  |   ^^^^^^^ NameError\n" +
+				"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		errObj, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error)
+		if !ok {
+			t.Fatalf("%q: expected an error", tt.input)
+		}
+
+		d := diagnostic.FromError(errObj)
+		if d.Code != tt.code {
+			t.Errorf("%q: wrong code. want=%s, got=%s", tt.input, tt.code, d.Code)
+		}
+		if d.Primary.Span.Start != tt.start || d.Primary.Span.End != tt.end {
+			t.Errorf("%q: wrong span. want=%v-%v, got=%v-%v",
+				tt.input, tt.start, tt.end, d.Primary.Span.Start, d.Primary.Span.End)
+		}
+
+		var out bytes.Buffer
+		diagnostic.Render(&out, "main.morty", tt.input, []diagnostic.Diagnostic{d})
+		if out.String() != tt.rendered {
+			t.Errorf("wrong rendering. want=\n%s\ngot=\n%s", tt.rendered, out.String())
+		}
+	}
+}
+
+func TestFromErrorWithoutNode(t *testing.T) {
+	d := diagnostic.FromError(&object.Error{Kind: object.STEP_LIMIT_ERROR, Message: "step limit of 1 exceeded"})
+	if d.Code != diagnostic.StepLimit || !d.Primary.Span.IsZero() {
+		t.Errorf("wrong diagnostic %+v", d)
+	}
+
+	var out bytes.Buffer
+	diagnostic.Render(&out, "main.morty", "", []diagnostic.Diagnostic{d})
+	expected := "error[E0302]: step limit of 1 exceeded\n" +
+		"  = note: this error cannot be caught by try\n" +
+		"\n"
+	if out.String() != expected {
+		t.Errorf("wrong rendering. want=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	p := parser.New(lexer.New("let = 1;"))
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	if err := diagnostic.WriteJSON(&out, "main.morty", p.Diagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		File        string
+		Diagnostics []struct {
+			Severity string
+			Code     string
+			Message  string
+			Primary  struct {
+				Span struct {
+					Start struct{ Offset, Line, Column int }
+				}
+				Message string
+			}
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %s", out.String(), err)
+	}
+
+	if decoded.File != "main.morty" || len(decoded.Diagnostics) != 1 {
+		t.Fatalf("wrong JSON %s", out.String())
+	}
+	d := decoded.Diagnostics[0]
+	if d.Severity != "error" || d.Code != "E0102" || d.Primary.Span.Start.Column != 5 {
+		t.Errorf("wrong JSON %s", out.String())
+	}
+
+	out.Reset()
+	diagnostic.WriteJSON(&out, "ok.morty", nil)
+	if out.String() != `{"file":"ok.morty","diagnostics":[]}`+"\n" {
+		t.Errorf("wrong JSON for no diagnostics %s", out.String())
+	}
+}
+
+func TestCodeTitles(t *testing.T) {
+	codes := []diagnostic.Code{
+		diagnostic.IllegalCharacter, diagnostic.UnexpectedToken, diagnostic.InvalidInteger, diagnostic.InvalidAssignTarget, diagnostic.MissingHandler, diagnostic.UnclosedDelimiter,
+		diagnostic.UndefinedName, diagnostic.UnusedBinding, diagnostic.ShadowedBinding, diagnostic.UnreachableCode,
+		diagnostic.WrongArgCount, diagnostic.ConstantCondition, diagnostic.LiteralMismatch,
+		diagnostic.UncaughtError, diagnostic.TypeError, diagnostic.NameError, diagnostic.ArityError, diagnostic.ZeroDivision, diagnostic.PermissionDenied, diagnostic.NonExhaustiveMatch,
+		diagnostic.Cancelled, diagnostic.StepLimit, diagnostic.AllocationLimit, diagnostic.OutputLimit, diagnostic.UnknownRuntimeKind,
+		diagnostic.UnknownType, diagnostic.MismatchedTypes, diagnostic.NullableOperand, diagnostic.NotCallable, diagnostic.ArgumentCount, diagnostic.IncompatibleType, diagnostic.UnknownName,
+	}
+	for _, code := range codes {
+		if code.Title() == "" {
+			t.Errorf("%s has no title", code)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Render writes diags the way a terminal shows them: a header with the code,
+// then the source lines they point at, underlined, then notes and suggestions.
+// name is the file the source src was read from.
+func Render(w io.Writer, name, src string, diags []Diagnostic) {
+	lines := strings.Split(src, "\n")
+	for _, d := range diags {
+		renderOne(w, name, lines, d)
+	}
+}
+
+type marked struct {
+	Label
+	mark byte
+}
+
+func renderOne(w io.Writer, name string, lines []string, d Diagnostic) {
+	fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+
+	labels := []marked{}
+	if !d.Primary.Span.IsZero() {
+		labels = append(labels, marked{d.Primary, '^'})
+	}
+	for _, l := range d.Secondary {
+		if !l.Span.IsZero() {
+			labels = append(labels, marked{l, '-'})
+		}
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Span.Start.Line < labels[j].Span.Start.Line
+	})
+
+	gutter := 1
+	for _, l := range labels {
+		gutter = max(gutter, len(strconv.Itoa(l.Span.Start.Line)))
+	}
+	pad := strings.Repeat(" ", gutter)
+
+	if len(labels) > 0 {
+		start := d.Primary.Span.Start
+		if d.Primary.Span.IsZero() {
+			start = labels[0].Span.Start
+		}
+		fmt.Fprintf(w, "%s--> %s:%d:%d\n", pad, name, start.Line, start.Column)
+		fmt.Fprintf(w, "%s |\n", pad)
+	}
+
+	for i, l := range labels {
+		line := ""
+		if n := l.Span.Start.Line; n <= len(lines) {
+			line = strings.TrimRight(lines[n-1], "\r")
+		}
+		if i == 0 || labels[i-1].Span.Start.Line != l.Span.Start.Line {
+			fmt.Fprintf(w, "%*d | %s\n", gutter, l.Span.Start.Line, line)
+		}
+		fmt.Fprintf(w, "%s | %s\n", pad, underline(line, l))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", pad, note)
+	}
+	for _, s := range d.Suggestions {
+		fmt.Fprintf(w, "%s = help: %s\n", pad, s.Message)
+	}
+	fmt.Fprintln(w)
+}
+
+// underline marks the columns of l on line, keeping tabs so that the marks line up.
+func underline(line string, l marked) string {
+	var b strings.Builder
+	col := min(l.Span.Start.Column-1, len(line))
+	for _, ch := range []byte(line[:col]) {
+		if ch == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+
+	width := l.Span.End.Column - l.Span.Start.Column
+	if l.Span.End.Line != l.Span.Start.Line {
+		width = len(line) - col
+	}
+	b.WriteString(strings.Repeat(string(l.mark), max(width, 1)))
+
+	if l.Message != "" {
+		b.WriteString(" " + l.Message)
+	}
+	return b.String()
+}
+
+// WriteJSON writes the diagnostics of the file name as one JSON object on a line,
+// for editors and CI.
+func WriteJSON(w io.Writer, name string, diags []Diagnostic) error {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return json.NewEncoder(w).Encode(struct {
+		File        string       `json:"file"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}{name, diags})
+}
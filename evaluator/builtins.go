@@ -1,22 +1,185 @@
 package evaluator
 
 import (
+	"fmt"
+	"io"
 	"morty/object"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number fo arguments, got=%d, want=1", len(args))
-			}
+// Registry holds the builtins visible to an evaluator. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	builtins map[string]*object.Builtin
+}
+
+// NewRegistry returns a registry holding the standard builtins.
+func NewRegistry() *Registry {
+	r := &Registry{builtins: make(map[string]*object.Builtin)}
+	for _, list := range [][]*object.Builtin{standardBuiltins, concurrencyBuiltins, resultBuiltins} {
+		for _, b := range list {
+			r.builtins[b.Name] = b
+		}
+	}
+	return r
+}
+
+// Register adds b to the registry, replacing any builtin with the same name.
+func (r *Registry) Register(b *object.Builtin) error {
+	if b.Name == "" {
+		return fmt.Errorf("builtin has no name")
+	}
+	if b.Fn == nil && b.RuntimeFn == nil {
+		return fmt.Errorf("builtin %s has no function", b.Name)
+	}
+	if b.Arity < object.VARIADIC {
+		return fmt.Errorf("builtin %s has invalid arity %d", b.Name, b.Arity)
+	}
+	r.mu.Lock()
+	r.builtins[b.Name] = b
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) Lookup(name string) (*object.Builtin, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.builtins[name]
+	return b, ok
+}
+
+// Names returns the sorted names of all registered builtins.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.builtins))
+	for name := range r.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
+var standardBuiltins = []*object.Builtin{
+	{
+		Name:  "len",
+		Arity: 1,
+		Doc:   "len(x) returns the number of bytes in the string x or the number of elements in the array x.",
+		Fn: func(args ...object.Object) object.Object {
 			switch arg := args[0].(type) {
 			case *object.String:
 				return &object.Integer{Value: int64(len(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
-				return newError("argument to `len` not supported, got=%s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	{
+		Name:  "puts",
+		Arity: object.VARIADIC,
+		Doc:   "puts(args...) prints each argument on its own line.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			for _, arg := range args {
+				if _, err := io.WriteString(rt.Output(), arg.Inspect()+"\n"); err != nil {
+					return newOutputError(err)
+				}
+			}
+			return NULL
+		},
+	},
+	{
+		Name:         "read_file",
+		Arity:        1,
+		Doc:          "read_file(path) returns the contents of the file at path.",
+		Capabilities: []string{CAP_FS_READ},
+		Fn: func(args ...object.Object) object.Object {
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `read_file` must be STRING, got %s", args[0].Type())
 			}
+			data, err := os.ReadFile(path.Value)
+			if err != nil {
+				return newError(object.ERROR, "read_file: %s", err)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	{
+		Name:         "write_file",
+		Arity:        2,
+		Doc:          "write_file(path, s) replaces the contents of the file at path with s.",
+		Capabilities: []string{CAP_FS_WRITE},
+		Fn: func(args ...object.Object) object.Object {
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "first argument to `write_file` must be STRING, got %s", args[0].Type())
+			}
+			data, ok := args[1].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "second argument to `write_file` must be STRING, got %s", args[1].Type())
+			}
+			if err := os.WriteFile(path.Value, []byte(data.Value), 0o644); err != nil {
+				return newError(object.ERROR, "write_file: %s", err)
+			}
+			return NULL
+		},
+	},
+	{
+		Name:         "exec",
+		Arity:        object.VARIADIC,
+		Doc:          "exec(name, args...) runs a program and returns its standard output.",
+		Capabilities: []string{CAP_PROC},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARITY_ERROR, "wrong number of arguments. got=0, want at least 1")
+			}
+			strs := make([]string, 0, len(args))
+			for _, arg := range args {
+				str, ok := arg.(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "arguments to `exec` must be STRING, got %s", arg.Type())
+				}
+				strs = append(strs, str.Value)
+			}
+			out, err := exec.Command(strs[0], strs[1:]...).Output()
+			if err != nil {
+				return newError(object.ERROR, "exec: %s", err)
+			}
+			return &object.String{Value: string(out)}
+		},
+	},
+	{
+		Name:         "now",
+		Arity:        0,
+		Doc:          "now() returns the current Unix time in milliseconds.",
+		Capabilities: []string{CAP_CLOCK},
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: time.Now().UnixMilli()}
+		},
+	},
+	{
+		Name:         "getenv",
+		Arity:        1,
+		Doc:          "getenv(name) returns the value of the environment variable name, or null if unset.",
+		Capabilities: []string{CAP_ENV},
+		Fn: func(args ...object.Object) object.Object {
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `getenv` must be STRING, got %s", args[0].Type())
+			}
+			val, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return NULL
+			}
+			return &object.String{Value: val}
 		},
 	},
 }
+
+var builtins = NewRegistry()
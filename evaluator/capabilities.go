@@ -0,0 +1,48 @@
+package evaluator
+
+import (
+	"morty/object"
+	"strings"
+)
+
+// Capabilities guarding the standard builtins
+const (
+	CAP_FS_READ  = "fs.read"
+	CAP_FS_WRITE = "fs.write"
+	CAP_PROC     = "proc"
+	CAP_CLOCK    = "clock"
+	CAP_ENV      = "env"
+	CAP_TASKS    = "tasks" // spawning goroutines
+)
+
+// AllCapabilities lists every capability required by a standard builtin.
+var AllCapabilities = []string{CAP_FS_READ, CAP_FS_WRITE, CAP_PROC, CAP_CLOCK, CAP_ENV, CAP_TASKS}
+
+// WithCapabilities grants caps to scripts. Builtins whose capabilities are
+// not all granted cannot be resolved or called, even when the host passes
+// them to Call; by default nothing is granted.
+func WithCapabilities(caps ...string) Option {
+	return func(e *Evaluator) {
+		e.granted = make(map[string]bool, len(caps))
+		for _, c := range caps {
+			e.granted[c] = true
+		}
+	}
+}
+
+func (e *Evaluator) checkCapabilities(b *object.Builtin) *object.Error {
+	var missing []string
+	for _, c := range b.Capabilities {
+		if !e.granted[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return &object.Error{
+		Kind:    object.PERMISSION_ERROR,
+		Message: "permission denied: `" + b.Name + "` requires capability " + strings.Join(missing, ", "),
+	}
+}
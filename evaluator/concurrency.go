@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"morty/object"
+	"reflect"
+)
+
+// maxChannelCapacity bounds the buffer of a channel, which is allocated up front.
+const maxChannelCapacity = 1 << 16
+
+// Spawned tasks share the evaluator, and so its context and limits, with the
+// script that started them; the Interpreter cancels that context once the
+// run returns. Each call gets its own environment enclosed by
+// the closure of the function, like any other call: bindings made by a task
+// stay in the task, and the closure environments it reads are locked.
+var concurrencyBuiltins = []*object.Builtin{
+	{
+		Name:  "spawn",
+		Arity: object.VARIADIC,
+		Doc:   "spawn(f, args...) calls f with args on a new goroutine and returns a task to wait on.",
+		// goroutines are not bounded by the limits, so the host has to allow them
+		Capabilities: []string{CAP_TASKS},
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARITY_ERROR, "wrong number of arguments. got=0, want at least 1")
+			}
+			switch args[0].(type) {
+			case *object.Function, *object.Builtin:
+			default:
+				return newError(object.TYPE_ERROR, "first argument to `spawn` must be FUNCTION, got %s", args[0].Type())
+			}
+
+			task := object.NewTask()
+			go func(fn object.Object, args []object.Object) {
+				defer func() {
+					// a panicking host function fails the task, not the process
+					if r := recover(); r != nil {
+						task.Finish(newError(object.ERROR, "task panicked: %v", r))
+					}
+				}()
+				task.Finish(rt.Call(fn, args...))
+			}(args[0], args[1:])
+			return task
+		},
+	},
+	{
+		Name:  "wait",
+		Arity: object.VARIADIC,
+		Doc:   "wait(tasks...) blocks until the tasks finish and returns the result of one task, or an array of results.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARITY_ERROR, "wrong number of arguments. got=0, want at least 1")
+			}
+
+			results := make([]object.Object, 0, len(args))
+			for _, arg := range args {
+				task, ok := arg.(*object.Task)
+				if !ok {
+					return newError(object.TYPE_ERROR, "arguments to `wait` must be TASK, got %s", arg.Type())
+				}
+				select {
+				case <-task.Done():
+				case <-rt.Context().Done():
+					return newCancelledError(rt.Context().Err())
+				}
+				if isError(task.Result()) {
+					return task.Result()
+				}
+				results = append(results, task.Result())
+			}
+
+			if len(results) == 1 {
+				return results[0]
+			}
+			return &object.Array{Elements: results}
+		},
+	},
+	{
+		Name:  "channel",
+		Arity: object.VARIADIC,
+		Doc:   "channel(capacity) returns a new channel buffering up to capacity values, unbuffered by default.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError(object.ARITY_ERROR, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			capacity := int64(0)
+			if len(args) == 1 {
+				n, ok := args[0].(*object.Integer)
+				if !ok || n.Value < 0 {
+					return newError(object.TYPE_ERROR, "argument to `channel` must be a non-negative INTEGER, got %s", args[0].Inspect())
+				}
+				capacity = n.Value
+			}
+			if capacity > maxChannelCapacity {
+				return newError(object.TYPE_ERROR, "capacity of `channel` must be at most %d, got %d", maxChannelCapacity, capacity)
+			}
+			// account charges the buffer once made, too late to refuse it
+			if e, ok := rt.(*Evaluator); ok {
+				if err := e.canAllocate(8 * capacity); err != nil {
+					return err
+				}
+			}
+			return object.NewChannel(int(capacity))
+		},
+	},
+	{
+		Name:  "send",
+		Arity: 2,
+		Doc:   "send(ch, value) sends value on ch, blocking until it is received or buffered.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) (result object.Object) {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(object.TYPE_ERROR, "first argument to `send` must be CHANNEL, got %s", args[0].Type())
+			}
+			if ch.Closed() {
+				return newError(object.ERROR, "send on closed channel")
+			}
+			defer func() {
+				// the channel was closed while we were blocked
+				if recover() != nil {
+					result = newError(object.ERROR, "send on closed channel")
+				}
+			}()
+
+			select {
+			case ch.C <- args[1]:
+				return NULL
+			case <-rt.Context().Done():
+				return newCancelledError(rt.Context().Err())
+			}
+		},
+	},
+	{
+		Name:  "recv",
+		Arity: 1,
+		Doc:   "recv(ch) returns the next value sent on ch, or null once ch is closed and drained.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `recv` must be CHANNEL, got %s", args[0].Type())
+			}
+
+			select {
+			case val, ok := <-ch.C:
+				if !ok {
+					return NULL
+				}
+				return val
+			case <-rt.Context().Done():
+				return newCancelledError(rt.Context().Err())
+			}
+		},
+	},
+	{
+		Name:  "close",
+		Arity: 1,
+		Doc:   "close(ch) closes ch; pending and later recv calls return null once it is drained.",
+		Fn: func(args ...object.Object) object.Object {
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `close` must be CHANNEL, got %s", args[0].Type())
+			}
+			if !ch.Close() {
+				return newError(object.ERROR, "close of closed channel")
+			}
+			return NULL
+		},
+	},
+	{
+		Name:  "select",
+		Arity: object.VARIADIC,
+		Doc:   "select(chs...) waits for a value on any of chs and returns [index, value]; value is null if that channel was closed.",
+		RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARITY_ERROR, "wrong number of arguments. got=0, want at least 1")
+			}
+
+			cases := make([]reflect.SelectCase, 0, len(args)+1)
+			for _, arg := range args {
+				ch, ok := arg.(*object.Channel)
+				if !ok {
+					return newError(object.TYPE_ERROR, "arguments to `select` must be CHANNEL, got %s", arg.Type())
+				}
+				cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.C)})
+			}
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(rt.Context().Done())})
+
+			chosen, val, ok := reflect.Select(cases)
+			if chosen == len(args) {
+				return newCancelledError(rt.Context().Err())
+			}
+
+			var received object.Object = NULL
+			if ok {
+				received = val.Interface().(object.Object)
+			}
+			return &object.Array{Elements: []object.Object{&object.Integer{Value: int64(chosen)}, received}}
+		},
+	},
+}
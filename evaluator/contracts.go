@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"fmt"
+	"morty/ast"
+	"morty/object"
+)
+
+// typeNames maps the named types of annotations to the objects they accept.
+// any accepts every object, the name of a trait the values of the types
+// having its methods, and other names the values of the struct or enum so named.
+var typeNames = map[string]object.ObjectType{
+	"int":    object.INTEGER_OBJ,
+	"string": object.STRING_OBJ,
+	"bool":   object.BOOLEAN_OBJ,
+	"null":   object.NULL_OBJ,
+}
+
+// checkArgument returns a type error unless arg fits the annotation of the i-th parameter of fn.
+func checkArgument(fn *object.Function, i int, arg object.Object) *object.Error {
+	if i >= len(fn.ParameterTypes) || fn.ParameterTypes[i] == nil {
+		return nil
+	}
+	if name := unknownType(fn.ParameterTypes[i], fn.Env); name != "" {
+		return newError(object.TYPE_ERROR, "%s: parameter %s has unknown type %s", functionName(fn), fn.Parameters[i].Value, name)
+	}
+	if matches(arg, fn.ParameterTypes[i], fn.Env) {
+		return nil
+	}
+	err := newError(object.TYPE_ERROR, "%s: parameter %s must be %s, got %s",
+		functionName(fn), fn.Parameters[i].Value, fn.ParameterTypes[i].ToString(), object.TypeName(arg))
+	if named, ok := fn.ParameterTypes[i].(*ast.NamedType); ok {
+		if trait, ok := traitNamed(fn.Env, named.Name); ok {
+			err.Message += fmt.Sprintf(" (missing method %s)", missingMethod(fn.Env, object.TypeName(arg), arg.Type(), trait))
+		}
+	}
+	return err
+}
+
+// checkResult returns a type error unless result fits the return annotation of fn.
+func checkResult(fn *object.Function, result object.Object) *object.Error {
+	if result == nil {
+		result = NULL // a body ending with a let
+	}
+	if fn.ReturnType == nil {
+		return nil
+	}
+	if name := unknownType(fn.ReturnType, fn.Env); name != "" {
+		return newError(object.TYPE_ERROR, "%s: unknown result type %s", functionName(fn), name)
+	}
+	if matches(result, fn.ReturnType, fn.Env) {
+		return nil
+	}
+	return newError(object.TYPE_ERROR, "%s: must return %s, got %s",
+		functionName(fn), fn.ReturnType.ToString(), object.TypeName(result))
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name != nil {
+		return "fn " + fn.Name.Value
+	}
+	return "anonymous fn"
+}
+
+// traitNamed returns the trait bound to name in env, if any.
+func traitNamed(env *object.Environment, name string) (*object.Trait, bool) {
+	obj, _ := env.Get(name)
+	trait, ok := obj.(*object.Trait)
+	return trait, ok
+}
+
+// unknownType returns the first name in typ that is neither a builtin type
+// nor a struct, enum or trait visible from env, or "".
+func unknownType(typ ast.TypeExpression, env *object.Environment) string {
+	unknown := ""
+	ast.Inspect(typ, func(n ast.Noder) bool {
+		named, ok := n.(*ast.NamedType)
+		if !ok || unknown != "" {
+			return unknown == ""
+		}
+		if _, ok := typeNames[named.Name]; ok || named.Name == "any" {
+			return true
+		}
+		obj, _ := env.Get(named.Name)
+		switch obj.(type) {
+		case *object.StructType, *object.EnumType, *object.Trait:
+		default:
+			unknown = named.Name
+		}
+		return true
+	})
+	return unknown
+}
+
+// matches reports whether obj fits typ, with the traits and methods of env.
+// Arrays are checked element by element; functions only by their number of
+// parameters, since the types of their parameters are checked when they are called.
+func matches(obj object.Object, typ ast.TypeExpression, env *object.Environment) bool {
+	switch typ := typ.(type) {
+	case *ast.NamedType:
+		if typ.Name == "any" {
+			return true
+		}
+		if want, ok := typeNames[typ.Name]; ok {
+			return obj.Type() == want
+		}
+		if trait, ok := traitNamed(env, typ.Name); ok {
+			return missingMethod(env, object.TypeName(obj), obj.Type(), trait) == ""
+		}
+		switch obj.(type) {
+		case *object.Struct, *object.EnumValue:
+			return object.TypeName(obj) == object.ObjectType(typ.Name)
+		}
+		return false
+
+	case *ast.ArrayType:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return false
+		}
+		for _, elem := range array.Elements {
+			if !matches(elem, typ.Element, env) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.FunctionType:
+		switch fn := obj.(type) {
+		case *object.Function:
+			return len(fn.Parameters) == len(typ.Parameters)
+		case *object.Builtin:
+			return fn.Arity == object.VARIADIC || fn.Arity == len(typ.Parameters)
+		case *object.Variant:
+			return len(fn.Fields) == len(typ.Parameters)
+		}
+		return false
+
+	case *ast.UnionType:
+		for _, member := range typ.Types {
+			if matches(obj, member, env) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,105 +1,257 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"morty/ast"
 	"morty/object"
+	"os"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
+// Evaluator evaluates nodes with its own set of builtins, stopping once its context is done.
+type Evaluator struct {
+	ctx      context.Context
+	builtins *Registry
+	out      io.Writer
+	limits   Limits
+	usage    *usage
+	granted  map[string]bool
+}
+
+type Option func(*Evaluator)
+
+func WithBuiltins(r *Registry) Option {
+	return func(e *Evaluator) {
+		e.builtins = r
+	}
+}
+
+// WithContext makes evaluation fail with a CancelledError once ctx is done.
+// The context is checked on every function call and top level statement.
+func WithContext(ctx context.Context) Option {
+	return func(e *Evaluator) {
+		e.ctx = ctx
+	}
+}
+
+// WithOutput sets the writer builtins such as puts print to. It defaults to os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(e *Evaluator) {
+		e.out = w
+	}
+}
+
+func New(opts ...Option) *Evaluator {
+	e := &Evaluator{ctx: context.Background(), builtins: builtins, out: os.Stdout, usage: &usage{}}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Eval evaluates node using the standard builtins.
 func Eval(node ast.Noder, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Noder, env *object.Environment) object.Object {
+	obj := e.eval(node, env)
+	if err, ok := obj.(*object.Error); ok && err.Node == nil {
+		if _, ok := node.(*ast.Program); !ok {
+			err.Node = node
+		}
+	}
+	return obj
+}
+
+func (e *Evaluator) eval(node ast.Noder, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return e.account(&object.Integer{Value: node.Value})
 
 	case *ast.Boolean:
 		return ToBoolObject(node.Value)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if unwinds(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.account(evalPrefixExpression(node.Operator, right))
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.Eval(node.Left, env)
+		if unwinds(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.Eval(node.Right, env)
+		if unwinds(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.account(e.evalInfixExpression(node.Operator, left, right))
 
 	case *ast.BlockStatement:
-		return evalBlockStatements(node, env)
+		return e.evalBlockStatements(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		val := e.Eval(node.ReturnValue, env)
+		if unwinds(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.Eval(node.Value, env)
+		if unwinds(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if fn, ok := val.(*object.Function); ok && fn.Name == nil {
+			if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+				fn.Name = node.Name // for messages
+			}
+		}
+		if bound := env.Set(node.Name.Value, val); isError(bound) {
+			return bound
+		}
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
-		funcLit_obj := evalFunctionLiteral(node, env) // Note: when evaling function decleration we only make an funcLit object
+		funcLit_obj := e.account(evalFunctionLiteral(node, env))
+		if isError(funcLit_obj) {
+			return funcLit_obj
+		} // Note: when evaling function decleration we only make an funcLit object
 		if node.Name != nil {
-			env.Set(node.Name.Value, funcLit_obj)
-			return nil
+			if bound := env.Set(node.Name.Value, funcLit_obj); isError(bound) {
+				return bound
+			}
 		}
 		return funcLit_obj
 
 	case *ast.CallExpression: // when evaling func call we actually eval the funcLit obj
-		function := Eval(node.Function, env)
-		if isError(function) {
+		if isQuoteCall(node) {
+			return e.quote(node, env)
+		}
+
+		if member, ok := node.Function.(*ast.MemberExpression); ok {
+			return e.evalMethodCall(member, node.Arguments, env)
+		}
+
+		function := e.Eval(node.Function, env)
+		if unwinds(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && unwinds(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return e.applyFunction(function, args)
 
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return e.account(&object.String{Value: node.Value})
+
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && unwinds(elements[0]) {
+			return elements[0]
+		}
+		return e.account(&object.Array{Elements: elements})
+
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if unwinds(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value)
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
+
+	case *ast.ThrowStatement:
+		val := e.Eval(node.Value, env)
+		if unwinds(val) {
+			return val
+		}
+		return newThrownError(val)
+
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+
+	case *ast.StructStatement:
+		def := &object.StructType{Name: node.Name.Value}
+		for _, field := range node.Fields {
+			def.Fields = append(def.Fields, field.Value)
+		}
+		if bound := env.Set(node.Name.Value, def); isError(bound) {
+			return bound
+		}
+
+	case *ast.StructLiteral:
+		return e.account(e.evalStructLiteral(node, env))
+
+	case *ast.TraitStatement:
+		trait := &object.Trait{Name: node.Name.Value}
+		for _, method := range node.Methods {
+			trait.Methods = append(trait.Methods, method.Value)
+		}
+		if bound := env.Set(node.Name.Value, trait); isError(bound) {
+			return bound
+		}
+
+	case *ast.ExtendStatement:
+		return e.evalExtendStatement(node, env)
+
+	case *ast.EnumStatement:
+		return evalEnumStatement(node, env)
+
+	case *ast.MacroLiteral:
+		return &object.Macro{Parameters: node.Parameters, Body: node.Body, Env: env}
+
+	case *ast.PostfixExpression:
+		left := e.Eval(node.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		return evalPostfixExpression(node.Operator, left)
 	}
 
 	return nil
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 
 	var result object.Object
 
 	for _, statements := range stmts {
-		result = Eval(statements, env)
+		if err := e.ctx.Err(); err != nil {
+			return newCancelledError(err)
+		}
+		result = e.Eval(statements, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -126,7 +278,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, object.TypeName(right))
 	}
 }
 
@@ -145,7 +297,7 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusOperatorExpression(rigth object.Object) object.Object {
 	if rigth.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", rigth.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", object.TypeName(rigth))
 	}
 
 	if rigth.Type() != object.INTEGER_OBJ {
@@ -156,7 +308,7 @@ func evalMinusOperatorExpression(rigth object.Object) object.Object {
 	return &object.Integer{Value: -value}
 }
 
-func evalInfixExpression(operator string, leftExp object.Object, rightExp object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, leftExp object.Object, rightExp object.Object) object.Object {
 	// if we compare two struct values directly, then go compares each fields one by one
 	// if we compare two struct pointers, then go checks if they both point to the same memory
 
@@ -165,19 +317,19 @@ func evalInfixExpression(operator string, leftExp object.Object, rightExp object
 		return evalIntegerInfixExpression(operator, leftExp, rightExp)
 
 	case leftExp.Type() == object.STRING_OBJ && rightExp.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, leftExp, rightExp)
+		return e.evalStringInfixExpression(operator, leftExp, rightExp)
 
 	case operator == "==":
-		return ToBoolObject(leftExp == rightExp) // left and right both point to either TRUE or FALSE structs
+		return ToBoolObject(equal(leftExp, rightExp, 0)) // booleans and null are singletons, structs compare their fields
 
 	case operator == "!=":
-		return ToBoolObject(leftExp != rightExp)
+		return ToBoolObject(!equal(leftExp, rightExp, 0))
 
 	case leftExp.Type() != rightExp.Type():
-		return newError("type mismatch: %s %s %s", leftExp.Type(), operator, rightExp.Type())
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", object.TypeName(leftExp), operator, object.TypeName(rightExp))
 
 	default:
-		return newError("unknown operator: %s %s %s", leftExp.Type(), operator, rightExp.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.TypeName(leftExp), operator, object.TypeName(rightExp))
 	}
 }
 
@@ -193,6 +345,9 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return ToBoolObject(leftVal < rightVal)
@@ -203,24 +358,40 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "!=":
 		return ToBoolObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 
 }
 
-func evalIfExpression(ife *ast.IfExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalIfExpression(ife *ast.IfExpression, env *object.Environment) object.Object {
 
-	condition := Eval(ife.Condition, env)
+	condition := e.Eval(ife.Condition, env)
+	if unwinds(condition) {
+		return condition
+	}
 
 	if isTruthy(condition) {
-		return Eval(ife.Concequence, env)
+		return e.Eval(ife.Concequence, env)
 	} else if ife.Alternative != nil {
-		return Eval(ife.Alternative, env)
+		return e.Eval(ife.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+// evalPostfixExpression applies ?, which unwraps ok(v) to v and returns
+// err(e) from the enclosing function.
+func evalPostfixExpression(operator string, left object.Object) object.Object {
+	result, ok := left.(*object.Result)
+	if operator != "?" || !ok {
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", object.TypeName(left), operator)
+	}
+	if !result.Ok {
+		return &object.ReturnValue{Value: result}
+	}
+	return result.Value
+}
+
 func isTruthy(conditionObj object.Object) bool {
 	switch conditionObj {
 	case NULL:
@@ -234,12 +405,12 @@ func isTruthy(conditionObj object.Object) bool {
 	}
 }
 
-func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatements(block *ast.BlockStatement, env *object.Environment) object.Object {
 
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -253,8 +424,12 @@ func evalBlockStatements(block *ast.BlockStatement, env *object.Environment) obj
 	return result
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+func newCancelledError(cause error) *object.Error {
+	return &object.Error{Kind: object.CANCELLED_ERROR, Message: "execution cancelled: " + cause.Error()}
 }
 
 func isError(obj object.Object) bool {
@@ -264,24 +439,36 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+// unwinds reports whether obj stops the evaluation of the enclosing expression:
+// an error, or a return out of the middle of an expression such as f()? + 1.
+func unwinds(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.RETURN_VALUE_OBJ
+	}
+	return false
+}
+
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins.Lookup(node.Value); ok {
+		if err := e.checkCapabilities(builtin); err != nil {
+			return err
+		}
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: "+node.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-	var result []object.Object
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if unwinds(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -290,30 +477,73 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Context returns the context the evaluator stops on.
+func (e *Evaluator) Context() context.Context {
+	return e.ctx
+}
+
+// Call applies fn to args, as a call expression in a script would.
+func (e *Evaluator) Call(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	if err := e.ctx.Err(); err != nil {
+		return newCancelledError(err)
+	}
+
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := setFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError(object.ARITY_ERROR, "wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		extendedEnv, err := setFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		evaluated := unwrapReturnValue(e.Eval(fn.Body, extendedEnv))
+		if isError(evaluated) {
+			return evaluated
+		}
+		if err := checkResult(fn, evaluated); err != nil {
+			return err
+		}
+		return evaluated
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if err := e.checkCapabilities(fn); err != nil {
+			return err
+		}
+		if fn.Arity != object.VARIADIC && len(args) != fn.Arity {
+			return newError(object.ARITY_ERROR, "wrong number of arguments. got=%d, want=%d", len(args), fn.Arity)
+		}
+		if fn.RuntimeFn != nil {
+			return e.account(fn.RuntimeFn(e, args...))
+		}
+		return e.account(fn.Fn(args...))
+
+	case *object.Variant:
+		return e.makeEnumValue(fn, args)
 
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
 	}
 }
 
-func setFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// setFunctionEnv binds the parameters of fn to args, which must fit the
+// parameter annotations.
+func setFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramidx, param := range fn.Parameters {
+		if err := checkArgument(fn, paramidx, args[paramidx]); err != nil {
+			return nil, err
+		}
 		env.Set(param.Value, args[paramidx])
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -324,13 +554,17 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *Evaluator) evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 
 	switch operator {
 	case "+":
+		// check before concatenating so a runaway string never gets built
+		if err := e.canAllocate(int64(len(leftVal) + len(rightVal))); err != nil {
+			return err
+		}
 		return &object.String{Value: leftVal + rightVal}
 
 	case "!=":
@@ -340,14 +574,99 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 		return ToBoolObject(leftVal == rightVal)
 
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 
 }
 
 func evalFunctionLiteral(funcLit *ast.FunctionLiteral, env *object.Environment) object.Object {
-	params := funcLit.Parameters
-	body := funcLit.Body
-	name := funcLit.Name
-	return &object.Function{Parameters: params, Name: name, Body: body, Env: env}
+	return &object.Function{
+		Name:           funcLit.Name,
+		Parameters:     funcLit.Parameters,
+		ParameterTypes: funcLit.ParameterTypes,
+		ReturnType:     funcLit.ReturnType,
+		Body:           funcLit.Body,
+		Env:            env,
+	}
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	getter, ok := obj.(object.MemberGetter)
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s has no member `%s`", object.TypeName(obj), name)
+	}
+
+	member, ok := getter.GetMember(name)
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s has no member `%s`", object.TypeName(obj), name)
+	}
+	return member
+}
+
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	target := node.Target.(*ast.MemberExpression) // the parser only accepts member targets
+
+	obj := e.Eval(target.Object, env)
+	if unwinds(obj) {
+		return obj
+	}
+
+	val := e.Eval(node.Value, env)
+	if unwinds(val) {
+		return val
+	}
+
+	setter, ok := obj.(object.MemberSetter)
+	if !ok {
+		return newError(object.TYPE_ERROR, "cannot assign to member `%s` of %s", target.Property.Value, object.TypeName(obj))
+	}
+	if err := setter.SetMember(target.Property.Value, val); err != nil {
+		return newError(object.TYPE_ERROR, "%s", err)
+	}
+	return val
+}
+
+func newThrownError(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.Exception: // rethrow
+		return &object.Error{Kind: val.Kind, Message: val.Message, Value: val.Value}
+	case *object.String:
+		return &object.Error{Kind: object.ERROR, Message: val.Value, Value: val}
+	default:
+		return &object.Error{Kind: object.ERROR, Message: val.Inspect(), Value: val}
+	}
+}
+
+func (e *Evaluator) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.Eval(node.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && errObj.Catchable() && node.Catch != nil {
+		kind := errObj.Kind
+		if kind == "" {
+			kind = object.ERROR
+		}
+		exception := e.account(&object.Exception{Kind: kind, Message: errObj.Message, Value: errObj.Value})
+		if isError(exception) {
+			result = exception
+		} else {
+			catchEnv := object.NewEnclosedEnvironment(env)
+			catchEnv.Set(node.CatchParam.Value, exception)
+			result = e.Eval(node.Catch, catchEnv)
+		}
+	}
+
+	if node.Finally != nil {
+		// finally runs however the try block ended, and only overrides that by unwinding itself
+		finally := e.Eval(node.Finally, env)
+		if finally != nil && (finally.Type() == object.ERROR_OBJ || finally.Type() == object.RETURN_VALUE_OBJ) {
+			if errObj, ok := result.(*object.Error); !ok || errObj.Catchable() {
+				result = finally
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
 }
@@ -1,10 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"morty/lexer"
 	"morty/object"
 	"morty/parser"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -227,6 +235,10 @@ func TestErrorHandling(t *testing.T) {
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			"let f = fn(x) { x }; f();",
+			"wrong number of arguments. got=0, want=1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -287,6 +299,16 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+// A named fn both binds its name and, unlike let, evaluates to the function.
+func TestNamedFunctionValue(t *testing.T) {
+	testIntegerObject(t, testEval("let f = fn twice(x) { x * 2 }; f(2) + twice(3)"), 10)
+
+	evaluated := testEval("fn twice(x) { x * 2 }")
+	if fn, ok := evaluated.(*object.Function); !ok || fn.Name == nil || fn.Name.Value != "twice" {
+		t.Errorf("expected the function twice, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -399,3 +421,744 @@ func TestBuiltinFunctions(t *testing.T) {
 		}
 	}
 }
+
+func TestContextCancellation(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(50);`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	evaluated := New(WithContext(ctx)).Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no Error object returned, got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.CANCELLED_ERROR {
+		t.Errorf("wrong error kind, expected=%q, got=%q", object.CANCELLED_ERROR, errObj.Kind)
+	}
+	if errObj.Message != "execution cancelled: context deadline exceeded" {
+		t.Errorf("wrong error message, got=%q", errObj.Message)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input        string
+		limits       Limits
+		expectedKind string
+	}{
+		{
+			"let f = fn(n) { f(n + 1) }; f(0);",
+			Limits{MaxSteps: 1000},
+			object.STEP_LIMIT_ERROR,
+		},
+		{
+			`let grow = fn(s) { grow(s + s) }; grow("ab");`,
+			Limits{MaxAllocBytes: 1 << 20},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"let f = fn(n) { f(n + 1) }; f(0);",
+			Limits{MaxAllocations: 100},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"let f = fn() { channel(1000); f() }; f();",
+			Limits{MaxAllocBytes: 1 << 20},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"channel(60000)",
+			Limits{MaxAllocBytes: 1 << 16},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"let f = fn() { ok(f); f() }; f();",
+			Limits{MaxSteps: 100000, MaxAllocations: 100},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"let g = fn() { g }; let f = fn() { spawn(g); f() }; f();",
+			Limits{MaxSteps: 100000, MaxAllocations: 100},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			"let f = fn() { try { throw f } catch (e) { e }; f() }; f();",
+			Limits{MaxSteps: 100000, MaxAllocations: 100},
+			object.ALLOCATION_LIMIT_ERROR,
+		},
+		{
+			`puts("0123456789"); puts("0123456789");`,
+			Limits{MaxOutputBytes: 15},
+			object.OUTPUT_LIMIT_ERROR,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		var out bytes.Buffer
+		evaluated := New(WithLimits(tt.limits), WithOutput(&out), WithCapabilities(CAP_TASKS)).Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no Error object returned for %q, got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.expectedKind {
+			t.Errorf("wrong error kind for %q, expected=%q, got=%q (%s)", tt.input, tt.expectedKind, errObj.Kind, errObj.Message)
+		}
+		if tt.limits.MaxOutputBytes > 0 && int64(out.Len()) > tt.limits.MaxOutputBytes {
+			t.Errorf("output exceeded limit, got=%q", out.String())
+		}
+	}
+}
+
+func TestWithinLimits(t *testing.T) {
+	program := parser.New(lexer.New(`let s = "ab" + "cd"; puts(s); len(s)`)).ParseProgram()
+	var out bytes.Buffer
+	limits := Limits{MaxSteps: 100, MaxAllocations: 100, MaxAllocBytes: 100, MaxOutputBytes: 100}
+
+	evaluated := New(WithLimits(limits), WithOutput(&out)).Eval(program, object.NewEnvironment())
+
+	testIntegerObject(t, evaluated, 4)
+	if out.String() != "abcd\n" {
+		t.Errorf("wrong output, got=%q", out.String())
+	}
+}
+
+func TestConcurrentAllocations(t *testing.T) {
+	e := New(WithLimits(Limits{MaxAllocations: 1000}))
+
+	var allocated atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !isError(e.account(&object.Integer{Value: 1})) {
+					allocated.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allocated.Load() != 1000 || e.usage.allocations.Load() != 1000 {
+		t.Errorf("wrong number of allocations, want=1000, got=%d (recorded %d)", allocated.Load(), e.usage.allocations.Load())
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.txt")
+	if err := os.WriteFile(path, []byte("wubba lubba"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	readInput := fmt.Sprintf(`read_file(%q)`, path)
+
+	tests := []struct {
+		input    string
+		granted  []string
+		expected interface{}
+	}{
+		{readInput, nil, "permission denied: `read_file` requires capability fs.read"},
+		{readInput, []string{CAP_FS_WRITE}, "permission denied: `read_file` requires capability fs.read"},
+		{readInput, []string{CAP_FS_READ}, "wubba lubba"},
+		{`let f = read_file; 1`, nil, "permission denied: `read_file` requires capability fs.read"},
+		{`let now = fn() { 7 }; now()`, nil, 7},
+		{`len("abc")`, nil, 3},
+		{`spawn(fn() { 1 })`, nil, "permission denied: `spawn` requires capability tasks"},
+		{`wait(spawn(fn() { 1 }))`, []string{CAP_TASKS}, 1},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New(WithCapabilities(tt.granted...)).Eval(program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Kind != object.PERMISSION_ERROR || errObj.Message != expected {
+					t.Errorf("wrong error, expected=%q, got=%s %q", expected, errObj.Kind, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestConcurrentEvalOverSharedPrelude(t *testing.T) {
+	prelude := object.NewEnvironment()
+	testEvalIn(`
+	let base = 10;
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	let addBase = fn(x) { x + base };`, prelude)
+	prelude.Freeze()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := object.NewEnclosedEnvironment(prelude)
+			env.Set("i", &object.Integer{Value: int64(i)})
+
+			evaluated := testEvalIn("let local = addBase(i); local + fib(10)", env)
+			testIntegerObject(t, evaluated, int64(i)+10+55)
+		}(i)
+	}
+	wg.Wait()
+
+	evaluated := testEvalIn("let base = 1;", prelude)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected binding in frozen prelude to fail, got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "cannot bind `base` in a frozen environment" {
+		t.Errorf("wrong error message, got=%q", errObj.Message)
+	}
+}
+
+func TestConcurrentEvalOverSharedEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			testEvalIn("let shared = 1; let f = fn() { shared }; f()", env)
+		}()
+	}
+	wg.Wait()
+
+	testIntegerObject(t, testEvalIn("shared", env), 1)
+}
+
+func testEvalIn(input string, env *object.Environment) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return Eval(program, env)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array, got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements, got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+
+	testIntegerObject(t, testEval("len([1, 2, 3])"), 3)
+	testIntegerObject(t, testEval("len([])"), 0)
+}
+
+func TestConcurrencyBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let t = spawn(fn(a, b) { a * b }, 6, 7); wait(t)", 42},
+		{"let sq = fn(x) { x * x }; len(wait(spawn(sq, 1), spawn(sq, 2), spawn(sq, 3)))", 3},
+		{"let ch = channel(1); send(ch, 5); recv(ch)", 5},
+		{
+			`let ch = channel();
+			let producer = fn(n) { send(ch, n * 10) };
+			spawn(producer, 4);
+			recv(ch)`,
+			40,
+		},
+		{
+			`let ch = channel(2);
+			let pump = fn(n) { if (n > 0) { send(ch, n); pump(n - 1) } else { close(ch) } };
+			wait(spawn(pump, 2));
+			[recv(ch), recv(ch), recv(ch)]`,
+			"[2, 1, null]",
+		},
+		{"let a = channel(); let b = channel(1); send(b, 9); select(a, b)", "[1, 9]"},
+		{"let ch = channel(); close(ch); select(ch)", "[0, null]"},
+		{"let ch = channel(); close(ch); send(ch, 1)", "ERROR:send on closed channel"},
+		{"let ch = channel(); close(ch); close(ch)", "ERROR:close of closed channel"},
+		{"wait(spawn(fn() { 1 + true }))", "ERROR:type mismatch: INTEGER + BOOLEAN"},
+		{"spawn(5)", "ERROR:first argument to `spawn` must be FUNCTION, got INTEGER"},
+		{"channel(1000000000)", "ERROR:capacity of `channel` must be at most 65536, got 1000000000"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New(WithCapabilities(CAP_TASKS)).Eval(program, object.NewEnvironment())
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestRecvStopsOnCancellation(t *testing.T) {
+	program := parser.New(lexer.New("recv(channel())")).ParseProgram()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := New(WithContext(ctx)).Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Kind != object.CANCELLED_ERROR {
+		t.Errorf("expected CancelledError, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 + true } catch (e) { e.kind }`, "TypeError"},
+		{`try { 1 + true } catch (e) { e.message }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { missing } catch (e) { e.kind }`, "NameError"},
+		{`try { len(1, 2) } catch (e) { e.kind }`, "ArityError"},
+		{`try { 1 / 0 } catch (e) { e.kind + ": " + e.message }`, "ZeroDivisionError: division by zero"},
+		{`try { throw "boom" } catch (e) { e.kind + " " + e.message }`, "Error boom"},
+		{`try { throw 42 } catch (e) { e.value + 1 }`, 43},
+		{`try { 10 } catch (e) { 20 }`, 10},
+		{`let f = fn() { throw "deep"; 1 }; try { f() } catch (e) { e.message }`, "deep"},
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { "outer " + e.message }`, "outer inner"},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn() { try { throw "x" } catch (e) { return 3 } }; f() + 1`, 4},
+		{`try { throw "x" } catch (e) { e }`, "Error: x"},
+		{`try { throw "lost" } finally { 1 }`, "ERROR:lost"},
+		{`throw "uncaught"; 5`, "ERROR:uncaught"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestTypeContracts(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`fn add(a: int, b: int) -> int { a + b } add(1, 2)`, 3},
+		{`fn add(a: int, b: int) -> int { a + b } add(1, "2")`, "ERROR:fn add: parameter b must be int, got STRING"},
+		{`let f = fn(s: string) { s }; f(true)`, "ERROR:fn f: parameter s must be string, got BOOLEAN"},
+		{`fn(s: string) { s }(true)`, "ERROR:anonymous fn: parameter s must be string, got BOOLEAN"},
+		{`let f = fn(s: string) { s }; let g = f; g(true)`, "ERROR:fn f: parameter s must be string, got BOOLEAN"},
+		{`fn f(x: itn) { x } f(1)`, "ERROR:fn f: parameter x has unknown type itn"},
+		{`fn f(x: [int | Pt]) { x } f([])`, "ERROR:fn f: parameter x has unknown type Pt"},
+		{`fn f() -> strng { "a" } f()`, "ERROR:fn f: unknown result type strng"},
+		{`fn f(x) -> int { if (x) { return "no" } 1 } f(false)`, 1},
+		{`fn f(x) -> int { if (x) { return "no" } 1 } f(true)`, "ERROR:fn f: must return int, got STRING"},
+		{`fn f() -> int { let x = 1; } f()`, "ERROR:fn f: must return int, got NULL"},
+		{`fn f(x: int | null) { x } f(if (false) { 1 })`, "null"},
+		{`fn f(x: int | null) -> string | null { if (x) { "set" } } f(1)`, "set"},
+		{`fn f(xs: [int]) { len(xs) } f([1, 2, 3])`, 3},
+		{`fn f(xs: [int]) { len(xs) } f([1, "2"])`, "ERROR:fn f: parameter xs must be [int], got ARRAY"},
+		{`fn f(g: fn(int) -> int) { g(2) } f(fn(x) { x * 3 })`, 6},
+		{`fn f(g: fn(int) -> int) { g(2) } f(send)`, "ERROR:fn f: parameter g must be fn(int) -> int, got BUILTIN"},
+		{`fn f(g: fn(int, int) -> int) { g(1, 2) } f(fn(x) { x })`, "ERROR:fn f: parameter g must be fn(int, int) -> int, got FUNCTION"},
+		{`fn f(x: any) -> any { x } f("anything")`, "anything"},
+		{`fn f(x: int) { x } try { f("1") } catch (e) { e.kind }`, "TypeError"},
+		{`fn f(x: int) -> int { x }; let r = fn() { f(ok(1)?) }; r()`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`struct Point { x, y } Point{x: 1, y: 2}`, "Point{x: 1, y: 2}"},
+		{`struct Point { x, y } Point{y: 2, x: 1}`, "Point{x: 1, y: 2}"},
+		{`struct Point { x, y } let p = Point{x: 1, y: 2}; p.x + p.y`, 3},
+		{`struct Point { x, y } let p = Point{x: 1, y: 2}; p.x = 10; p.x * p.y`, 20},
+		{`struct Point { x, y } let p = Point{x: 1, y: 2}; let q = p; q.x = 5; p.x`, 5},
+		{`struct Point { x, y } Point{x: 1, y: "a"} == Point{x: 1, y: "a"}`, "true"},
+		{`struct Point { x, y } Point{x: 1, y: 2} == Point{x: 2, y: 1}`, "false"},
+		{`struct Point { x, y } Point{x: 1, y: 2} != Point{x: 1, y: 2}`, "false"},
+		{`struct A { v } struct B { v } A{v: 1} == B{v: 1}`, "false"},
+		{`struct P { x } P{x: [1, [2]]} == P{x: [1, [2]]}`, "true"},
+		{`struct P { x } P{x: [1]} == P{x: [2]}`, "false"},
+		{`struct P { x } P{x: [1]} == P{x: [1, 1]}`, "false"},
+		{`struct Line { from, to } struct Point { x, y } let l = Line{from: Point{x: 0, y: 0}, to: Point{x: 1, y: 1}}; l.to.x = 3; l`, "Line{from: Point{x: 0, y: 0}, to: Point{x: 3, y: 1}}"},
+		{`struct Line { from, to } struct Point { x, y } Line{from: Point{x: 0, y: 0}, to: 1} == Line{from: Point{x: 0, y: 0}, to: 1}`, "true"},
+		{`struct Node { next } let n = Node{next: 0}; n.next = n; n`, "Node{next: Node{...}}"},
+		{`struct Node { next } let a = Node{next: 0}; a.next = a; let b = Node{next: 0}; b.next = b; a == b`, "false"},
+		{`struct Empty {} Empty{}`, "Empty{}"},
+		{`struct Point { x, y } Point`, "struct Point { x, y }"},
+		{`struct Point { x, y } Point{x: 1}`, "ERROR:missing field y of Point"},
+		{`struct Point { x, y } Point{x: 1, y: 2, z: 3}`, "ERROR:Point has no member `z`"},
+		{`struct Point { x, y } Point{x: 1, x: 2, y: 3}`, "ERROR:field x of Point given twice"},
+		{`struct Point { x, y } Point{x: 1, y: 2}.z`, "ERROR:Point has no member `z`"},
+		{`struct Point { x, y } let p = Point{x: 1, y: 2}; p.z = 1`, "ERROR:Point has no member `z`"},
+		{`let Point = 1; Point{x: 1}`, "ERROR:Point is not a struct, got INTEGER"},
+		{`struct Point { x, y } Point{x: 1, y: 2} + 1`, "ERROR:type mismatch: Point + INTEGER"},
+		{`struct Point { x, y } fn norm(p: Point) -> int { p.x * p.x + p.y * p.y } norm(Point{x: 3, y: 4})`, 25},
+		{`struct Point { x, y } fn norm(p: Point) { p.x } norm(1)`, "ERROR:fn norm: parameter p must be Point, got INTEGER"},
+		// a struct named after a builtin type is still a struct
+		{`struct INTEGER { x } -INTEGER{x: 1}`, "ERROR:unknown operator: -INTEGER"},
+		{`struct INTEGER { x } INTEGER{x: 1} + INTEGER{x: 2}`, "ERROR:unknown operator: INTEGER + INTEGER"},
+		{`struct STRING { x } STRING{x: 1}.upper()`, "ERROR:STRING has no method `upper`"},
+		{`struct INTEGER { v } fn f(x: int) { -x } f(INTEGER{v: 1})`, "ERROR:fn f: parameter x must be int, got INTEGER"},
+		{`struct ERROR { x } fn f() { ERROR{x: 1}; 2 } f()`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc".upper()`, "ABC"},
+		{`let s = "a,b,c"; s.split(",")`, "[a, b, c]"},
+		{`"  x ".trim().len()`, 1},
+		{`"morty".contains("or")`, "true"},
+		{`let n = -5; n.abs()`, 5},
+		{`[1, 2, 3].join("-")`, "1-2-3"},
+		{`[1, "a"].contains("a")`, "true"},
+		{`extend STRING { fn shout(self) { self + "!" } } "hey".shout()`, "hey!"},
+		{`extend INTEGER { fn plus(self, n) { self + n } } 1.plus(2).plus(3)`, 6},
+		{`extend STRING { fn upper(self) { "mine" } } "a".upper()`, "mine"},
+		{`let f = fn() { extend STRING { fn shout(self) { self + "!" } } "a".shout() }; f(); "b".shout()`, "ERROR:STRING has no method `shout`"},
+		{`struct Point { x, y } extend Point { fn sum(self) { self.x + self.y } } Point{x: 1, y: 2}.sum()`, 3},
+		{`struct Box { f } Box{f: fn(x) { x * 2 }}.f(4)`, 8},
+		{`try { throw "boom" } catch (e) { e.message.upper() }`, "BOOM"},
+		{`"abc".shout()`, "ERROR:STRING has no method `shout`"},
+		{`"a,b".split()`, "ERROR:wrong number of arguments to STRING.split. got=0, want=1"},
+		{`extend STRING { fn twice(self, n) { self } } "a".twice()`, "ERROR:wrong number of arguments to STRING.twice. got=0, want=1"},
+		{`"a".split(1)`, "ERROR:argument to `split` must be STRING, got INTEGER"},
+		{`extend Nope { fn f(self) { 1 } }`, "ERROR:cannot extend Nope, it is not a type"},
+		{`trait Shape { area, perimeter } Shape`, "trait Shape { area, perimeter }"},
+		{`trait Shape { area } struct Sq { s } extend Sq: Shape { fn area(self) { self.s * self.s } } Sq{s: 3}.area()`, 9},
+		{`trait Shape { area, perimeter } struct Sq { s } extend Sq: Shape { fn area(self) { 1 } }`, "ERROR:Sq does not implement Shape: missing method perimeter"},
+		{`trait Sized { len } extend STRING: Sized {} "ok"`, "ok"},
+		{`struct Sq { s } extend Sq: Sq {}`, "ERROR:Sq is not a trait"},
+		{`trait Shape { area } struct Sq { s } extend Sq { fn area(self) { self.s * self.s } } fn total(a: Shape, b: Shape) { a.area() + b.area() } total(Sq{s: 1}, Sq{s: 2})`, 5},
+		{`trait Shape { area } fn total(a: Shape) { a.area() } total("x")`, "ERROR:fn total: parameter a must be Shape, got STRING (missing method area)"},
+		{`trait Sized { len } fn size(x: Sized | bool) { if (x == true) { 0 } else { x.len() } } size([1, 2]) + size(true)`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	shapes := `enum Shape { Circle(r), Rect(w, h), Empty }
+	fn area(s) {
+		match (s) {
+			case Circle(r) { 3 * r * r }
+			case Rect(w, h) if w == h { w * w }
+			case Rect(w, h) { w * h }
+			case Empty { 0 }
+		}
+	}
+	`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{shapes + "area(Circle(2))", 12},
+		{shapes + "area(Rect(3, 3))", 9},
+		{shapes + "area(Rect(2, 5))", 10},
+		{shapes + "area(Empty)", 0},
+		{shapes + "area(Shape.Rect(1, 2)) + area(Shape.Empty)", 2},
+		{shapes + "Rect(1, 2)", "Rect(1, 2)"},
+		{shapes + "Rect(1, 2).h", 2},
+		{shapes + "Shape", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{shapes + "Circle", "Shape.Circle(r)"},
+		{shapes + "Circle(1) == Circle(1)", "true"},
+		{shapes + "Circle(1) == Circle(2)", "false"},
+		{shapes + "Empty == Empty", "true"},
+		{shapes + "fn f(s: Shape) { 1 } f(Empty)", 1},
+		{shapes + "fn f(s: Shape) { 1 } f(1)", "ERROR:fn f: parameter s must be Shape, got INTEGER"},
+		{`enum INTEGER { A(x) } A(1) + A(2)`, "ERROR:unknown operator: INTEGER + INTEGER"},
+		{`enum STRING { A } A.len()`, "ERROR:STRING has no method `len`"},
+		{`enum INTEGER { A(x) } fn f(n: int) { -n } f(A(1))`, "ERROR:fn f: parameter n must be int, got INTEGER"},
+		{`enum ERROR { A } fn f() { A; 2 } f()`, 2},
+		{shapes + "Circle(1, 2)", "ERROR:wrong number of arguments to Circle. got=2, want=1"},
+		{shapes + "extend Shape { fn round(self) { match (self) { case Circle { true } case _ { false } } } } Circle(5).round()", "true"},
+		{`match (2) { case 1 { "one" } case 2 { "two" } }`, "two"},
+		{`match ("b") { case "a" { 1 } case "b" { 2 } }`, 2},
+		{`match (-1) { case -1 { true } case _ { false } }`, "true"},
+		{`match (true) { case false { 0 } case true { 1 } }`, 1},
+		{`match (5) { case x if x > 10 { "big" } case x if x > 0 { "positive" } case _ { "other" } }`, "positive"},
+		{`match ([1, [2, 3]]) { case [a, [b, c]] { a + b + c } }`, 6},
+		{`match ([1, 2, 3]) { case [a, b] { 0 } case [a, _, c] { a + c } }`, 4},
+		{`let x = 10; match (1) { case x { x } }`, 1},
+		{`let x = 10; match (1) { case y { y } }; x`, 10},
+		{`enum Opt { Some(v), None } match (Some(Some(3))) { case Some(None) { 0 } case Some(Some(n)) { n } }`, 3},
+		{`match (3) { case 1 { "one" } case 2 { "two" } }`, "ERROR:non-exhaustive match: no case fits 3"},
+		{`match (-3) { case x if x > 0 { x } }`, "ERROR:non-exhaustive match: no case fits -3"},
+		{`try { match (0) { case 1 { 1 } } } catch (e) { e.kind }`, "MatchError"},
+		{`match (1) { case Nope { 1 } }`, "ERROR:Nope is not an enum variant"},
+		{`enum E { A(x) } match (A(1)) { case A(a, b) { 1 } }`, "ERROR:pattern A(a, b) has 2 fields, A has 1"},
+		{`fn f(x) { match (x) { case 0 { return 10 } case _ { 1 } }; 2 } f(0)`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestUncatchableErrors(t *testing.T) {
+	input := `let f = fn() { f() }; try { f() } catch (e) { 1 }`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	evaluated := New(WithLimits(Limits{MaxSteps: 200})).Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Kind != object.STEP_LIMIT_ERROR {
+		t.Errorf("expected StepLimitError to escape try, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`ok(5)`, "ok(5)"},
+		{`err("boom")`, "err(boom)"},
+		{`is_ok(ok(1))`, true},
+		{`is_err(ok(1))`, false},
+		{`is_err(err(1))`, true},
+		{`unwrap(ok(7))`, 7},
+		{`unwrap(err("boom"))`, "ERROR:boom"},
+		{`unwrap_or(err("boom"), 3)`, 3},
+		{`unwrap_or(ok(1), 3)`, 1},
+		{`let f = fn() { let x = ok(2)?; ok(x * 10) }; f()`, "ok(20)"},
+		{`let f = fn() { let x = err("bad")?; ok(x * 10) }; f()`, "err(bad)"},
+		{`let f = fn() { ok(unwrap(err("bad")) + 1) }; try { f() } catch (e) { e.message }`, "bad"},
+		{`let f = fn(r) { ok(r? + 1) }; [f(ok(1)), f(err(0))]`, "[ok(2), err(0)]"},
+		{`let f = fn(r) { if (r?) { ok("yes") } else { ok("no") } }; f(err("x"))`, "err(x)"},
+		{`5?`, "ERROR:unknown operator: INTEGER?"},
+		{`is_ok(5)`, "ERROR:argument to `is_ok` must be RESULT, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBoolObject(t, evaluated, expected)
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q, expected=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, `(8 + (4 + 4))`},
+		{`quote(f(unquote([1, 2])))`, `f([1, 2])`},
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("expected *object.Quote for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if quote.Node.ToString() != tt.expected {
+			t.Errorf("not equal for %q, got=%q, want=%q", tt.input, quote.Node.ToString(), tt.expected)
+		}
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	if err := DefineMacros(program, env); err != nil {
+		t.Fatalf("DefineMacros returned error: %s", err.Message)
+	}
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements, got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro, got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 || macro.Body.ToString() != "(x + y)" {
+		t.Errorf("wrong macro, got=%s", macro.Inspect())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(cond, cons, alt) {
+				quote(if (!(unquote(cond))) { unquote(cons); } else { unquote(alt); });
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let m = macro(x) { quote(unquote(x) * 2) }; [m(1), m(5)]`,
+			`[(1 * 2), (5 * 2)]`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := parser.New(lexer.New(tt.expected)).ParseProgram()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		env := object.NewEnvironment()
+		if err := DefineMacros(program, env); err != nil {
+			t.Fatalf("DefineMacros returned error: %s", err.Message)
+		}
+		expanded, err := New().ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("ExpandMacros returned error: %s", err.Message)
+		}
+
+		if expanded.ToString() != expected.ToString() {
+			t.Errorf("not equal, want=%q, got=%q", expected.ToString(), expanded.ToString())
+		}
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { 5 }; m(1)`, "macro `m` must return QUOTE, got INTEGER"},
+		{`let m = macro(x) { quote(x) }; m()`, "wrong number of arguments to macro `m`. got=0, want=1"},
+		{`let m = macro() { quote(unquote(fn(x) { x })) }; m()`, "cannot unquote FUNCTION"},
+		{`let m = macro() { 1 + true }; m()`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		if err := DefineMacros(program, env); err != nil {
+			t.Fatalf("DefineMacros returned error: %s", err.Message)
+		}
+
+		_, err := New().ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message, expected=%q, got=%q", tt.expected, err.Message)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"morty/object"
+	"sync"
+	"sync/atomic"
+)
+
+// Limits bounds the resources a single evaluation may use. Zero means unlimited.
+type Limits struct {
+	MaxSteps       int64 // calls to Eval
+	MaxAllocations int64 // integers, strings, arrays, functions and other values created
+	MaxAllocBytes  int64 // bytes held by the created objects
+	MaxOutputBytes int64 // bytes written to the output by builtins such as puts
+}
+
+// usage is shared by evaluators forked from the same run, so it is updated atomically.
+type usage struct {
+	steps       atomic.Int64
+	allocations atomic.Int64
+	allocBytes  atomic.Int64
+	output      atomic.Int64
+	outMu       sync.Mutex // serializes writes from spawned tasks
+}
+
+func WithLimits(l Limits) Option {
+	return func(e *Evaluator) {
+		e.limits = l
+	}
+}
+
+func (e *Evaluator) step() *object.Error {
+	if e.limits.MaxSteps == 0 {
+		return nil
+	}
+	if e.usage.steps.Add(1) > e.limits.MaxSteps {
+		return &object.Error{Kind: object.STEP_LIMIT_ERROR, Message: fmt.Sprintf("step limit of %d exceeded", e.limits.MaxSteps)}
+	}
+	return nil
+}
+
+// canAllocate reports an error if allocating size more bytes would exceed the limits, without recording them,
+// to refuse making an object too large before account records it.
+func (e *Evaluator) canAllocate(size int64) *object.Error {
+	if e.limits.MaxAllocations > 0 && e.usage.allocations.Load()+1 > e.limits.MaxAllocations {
+		return &object.Error{Kind: object.ALLOCATION_LIMIT_ERROR, Message: fmt.Sprintf("allocation limit of %d objects exceeded", e.limits.MaxAllocations)}
+	}
+	if e.limits.MaxAllocBytes > 0 && e.usage.allocBytes.Load()+size > e.limits.MaxAllocBytes {
+		return &object.Error{Kind: object.ALLOCATION_LIMIT_ERROR, Message: fmt.Sprintf("allocation limit of %d bytes exceeded", e.limits.MaxAllocBytes)}
+	}
+	return nil
+}
+
+// account records the allocation of obj, returning an error instead if it exceeds the limits.
+func (e *Evaluator) account(obj object.Object) object.Object {
+	if e.limits.MaxAllocations == 0 && e.limits.MaxAllocBytes == 0 {
+		return obj
+	}
+
+	var size int64
+	switch obj := obj.(type) {
+	case *object.Integer:
+		size = 8
+	case *object.String:
+		size = int64(len(obj.Value))
+	case *object.Array:
+		size = 8 * int64(len(obj.Elements))
+	case *object.Function:
+		size = 64
+	case *object.Struct:
+		size = 8 * int64(len(obj.Values))
+	case *object.EnumValue:
+		size = 8 * int64(len(obj.Values))
+	case *object.Channel:
+		size = 64 + 8*int64(cap(obj.C)) // each buffered slot
+	case *object.Task:
+		size = 64
+	case *object.Result:
+		size = 8
+	case *object.Exception:
+		size = 16 + int64(len(obj.Message))
+	default:
+		return obj // singletons, errors and host objects are not counted
+	}
+
+	if err := e.allocate(size); err != nil {
+		return err
+	}
+	return obj
+}
+
+// allocate records an object of size bytes, unless that exceeds the limits.
+// Each count is checked by the add recording it, so that tasks allocating
+// at once cannot exceed the limits together.
+func (e *Evaluator) allocate(size int64) *object.Error {
+	if n := e.usage.allocations.Add(1); e.limits.MaxAllocations > 0 && n > e.limits.MaxAllocations {
+		e.usage.allocations.Add(-1)
+		return &object.Error{Kind: object.ALLOCATION_LIMIT_ERROR, Message: fmt.Sprintf("allocation limit of %d objects exceeded", e.limits.MaxAllocations)}
+	}
+	if n := e.usage.allocBytes.Add(size); e.limits.MaxAllocBytes > 0 && n > e.limits.MaxAllocBytes {
+		e.usage.allocBytes.Add(-size)
+		e.usage.allocations.Add(-1)
+		return &object.Error{Kind: object.ALLOCATION_LIMIT_ERROR, Message: fmt.Sprintf("allocation limit of %d bytes exceeded", e.limits.MaxAllocBytes)}
+	}
+	return nil
+}
+
+var errOutputLimit = errors.New("output limit exceeded")
+
+// limitedWriter writes to the evaluator output until MaxOutputBytes is reached.
+type limitedWriter struct {
+	e *Evaluator
+}
+
+func (w limitedWriter) Write(p []byte) (int, error) {
+	max := w.e.limits.MaxOutputBytes
+	if max > 0 && w.e.usage.output.Add(int64(len(p))) > max {
+		return 0, errOutputLimit
+	}
+	w.e.usage.outMu.Lock()
+	defer w.e.usage.outMu.Unlock()
+	return w.e.out.Write(p)
+}
+
+// Output returns the writer builtins print to.
+func (e *Evaluator) Output() io.Writer {
+	return limitedWriter{e: e}
+}
+
+func newOutputError(err error) *object.Error {
+	if errors.Is(err, errOutputLimit) {
+		return &object.Error{Kind: object.OUTPUT_LIMIT_ERROR, Message: err.Error()}
+	}
+	return newError(object.ERROR, "write failed: %s", err)
+}
@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"morty/ast"
+	"morty/object"
+)
+
+// DefineMacros binds the macros defined by top level let statements of
+// program in env and removes those statements from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) *object.Error {
+	statements := []ast.Statement{}
+
+	for _, statement := range program.Statements {
+		let, ok := statement.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+		literal, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, statement)
+			continue
+		}
+
+		macro := &object.Macro{Parameters: literal.Parameters, Body: literal.Body, Env: env}
+		if bound, ok := env.Set(let.Name.Value, macro).(*object.Error); ok {
+			return bound
+		}
+	}
+
+	program.Statements = statements
+	return nil
+}
+
+// ExpandMacros replaces every call of a macro bound in env with the code the
+// macro returns. Macro arguments are passed as quotes, unevaluated.
+func (e *Evaluator) ExpandMacros(program ast.Noder, env *object.Environment) (ast.Noder, *object.Error) {
+	var failure *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Noder) ast.Noder {
+		if failure != nil {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, name, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			failure = newError(object.ARITY_ERROR, "wrong number of arguments to macro `%s`. got=%d, want=%d", name, len(call.Arguments), len(macro.Parameters))
+			return node
+		}
+
+		evaluated := unwrapReturnValue(e.Eval(macro.Body, extendMacroEnv(macro, call.Arguments)))
+		if errObj, ok := evaluated.(*object.Error); ok {
+			failure = errObj
+			return node
+		}
+
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			failure = newError(object.TYPE_ERROR, "macro `%s` must return QUOTE, got %s", name, typeOf(evaluated))
+			return node
+		}
+		return quote.Node
+	})
+
+	return expanded, failure
+}
+
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, string, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, "", false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, "", false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ident.Value, ok
+}
+
+func extendMacroEnv(macro *object.Macro, args []ast.Expression) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, &object.Quote{Node: args[paramIdx]})
+	}
+
+	return extended
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"morty/ast"
+	"morty/object"
+)
+
+func evalEnumStatement(node *ast.EnumStatement, env *object.Environment) object.Object {
+	def := &object.EnumType{Name: node.Name.Value}
+	if bound := env.Set(def.Name, def); isError(bound) {
+		return bound
+	}
+
+	for i, name := range node.Variants {
+		fields := []string{}
+		for _, field := range node.Fields[i] {
+			fields = append(fields, field.Value)
+		}
+		variant := object.NewVariant(def, name.Value, fields)
+		if bound := env.Set(name.Value, variant.Binding()); isError(bound) {
+			return bound
+		}
+	}
+	return nil
+}
+
+func (e *Evaluator) makeEnumValue(variant *object.Variant, args []object.Object) object.Object {
+	if len(args) != len(variant.Fields) {
+		return newError(object.ARITY_ERROR, "wrong number of arguments to %s. got=%d, want=%d", variant.Name, len(args), len(variant.Fields))
+	}
+	return e.account(&object.EnumValue{Variant: variant, Values: args})
+}
+
+// evalMatchExpression evaluates the body of the first case fitting the
+// subject, with the names its pattern binds. A subject no case fits is a
+// MatchError.
+func (e *Evaluator) evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := e.Eval(node.Subject, env)
+	if unwinds(subject) {
+		return subject
+	}
+
+	for _, c := range node.Cases {
+		caseEnv := object.NewEnclosedEnvironment(env)
+		ok, err := e.match(c.Pattern, subject, caseEnv)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if c.Guard != nil {
+			guard := e.Eval(c.Guard, caseEnv)
+			if unwinds(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return e.Eval(c.Body, caseEnv)
+	}
+
+	return newError(object.MATCH_ERROR, "non-exhaustive match: no case fits %s", subject.Inspect())
+}
+
+// match reports whether val fits pat, binding the names of pat in env.
+func (e *Evaluator) match(pat ast.Pattern, val object.Object, env *object.Environment) (bool, *object.Error) {
+	switch pat := pat.(type) {
+	case *ast.WildcardPattern:
+		return true, nil
+
+	case *ast.BindingPattern:
+		env.Set(pat.Name.Value, val)
+		return true, nil
+
+	case *ast.LiteralPattern:
+		literal := e.Eval(pat.Value, env)
+		if err, ok := literal.(*object.Error); ok {
+			return false, err
+		}
+		return valuesEqual(literal, val, 0), nil
+
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok || len(array.Elements) != len(pat.Elements) {
+			return false, nil
+		}
+		for i, el := range pat.Elements {
+			if ok, err := e.match(el, array.Elements[i], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case *ast.VariantPattern:
+		variant, err := lookupVariant(pat, env)
+		if err != nil {
+			return false, err
+		}
+		value, ok := val.(*object.EnumValue)
+		if !ok || value.Variant != variant {
+			return false, nil
+		}
+		for i, field := range pat.Fields {
+			if ok, err := e.match(field, value.Values[i], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// lookupVariant returns the variant pat names, which must have as many fields as pat.
+func lookupVariant(pat *ast.VariantPattern, env *object.Environment) (*object.Variant, *object.Error) {
+	var variant *object.Variant
+	switch obj, _ := env.Get(pat.Name.Value); obj := obj.(type) {
+	case *object.Variant:
+		variant = obj
+	case *object.EnumValue:
+		variant = obj.Variant
+	default:
+		return nil, newError(object.NAME_ERROR, "%s is not an enum variant", pat.Name.Value)
+	}
+
+	if len(pat.Fields) > 0 && len(pat.Fields) != len(variant.Fields) {
+		return nil, newError(object.TYPE_ERROR, "pattern %s has %d fields, %s has %d",
+			pat.ToString(), len(pat.Fields), variant.Name, len(variant.Fields))
+	}
+	return variant, nil
+}
@@ -0,0 +1,257 @@
+package evaluator
+
+import (
+	"morty/ast"
+	"morty/object"
+	"strings"
+)
+
+// extendable are the builtin types scripts may add methods to, besides structs and enums.
+var extendable = map[string]bool{
+	object.INTEGER_OBJ:   true,
+	object.BOOLEAN_OBJ:   true,
+	object.NULL_OBJ:      true,
+	object.STRING_OBJ:    true,
+	object.ARRAY_OBJ:     true,
+	object.FUNCTION_OBJ:  true,
+	object.BUILTIN_OBJ:   true,
+	object.EXCEPTION_OBJ: true,
+	object.RESULT_OBJ:    true,
+	object.TASK_OBJ:      true,
+	object.CHANNEL_OBJ:   true,
+}
+
+// builtinMethods are the methods values have without any extend, by the
+// type of the receiver. Their first argument is the receiver.
+var builtinMethods = map[object.ObjectType]map[string]*object.Builtin{
+	object.STRING_OBJ: methods(
+		&object.Builtin{
+			Name:  "len",
+			Arity: 1,
+			Doc:   "s.len() returns the number of bytes in s.",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.Integer{Value: int64(len(args[0].(*object.String).Value))}
+			},
+		},
+		&object.Builtin{
+			Name:  "upper",
+			Arity: 1,
+			Doc:   "s.upper() returns s with all letters in upper case.",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+			},
+		},
+		&object.Builtin{
+			Name:  "lower",
+			Arity: 1,
+			Doc:   "s.lower() returns s with all letters in lower case.",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+			},
+		},
+		&object.Builtin{
+			Name:  "trim",
+			Arity: 1,
+			Doc:   "s.trim() returns s without its leading and trailing white space.",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.String{Value: strings.TrimSpace(args[0].(*object.String).Value)}
+			},
+		},
+		&object.Builtin{
+			Name:  "split",
+			Arity: 2,
+			Doc:   "s.split(sep) returns the parts of s between the occurrences of sep.",
+			Fn: func(args ...object.Object) object.Object {
+				sep, ok := args[1].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument to `split` must be STRING, got %s", args[1].Type())
+				}
+				parts := &object.Array{}
+				for _, part := range strings.Split(args[0].(*object.String).Value, sep.Value) {
+					parts.Elements = append(parts.Elements, &object.String{Value: part})
+				}
+				return parts
+			},
+		},
+		&object.Builtin{
+			Name:  "contains",
+			Arity: 2,
+			Doc:   "s.contains(sub) reports whether sub is within s.",
+			Fn: func(args ...object.Object) object.Object {
+				sub, ok := args[1].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument to `contains` must be STRING, got %s", args[1].Type())
+				}
+				return ToBoolObject(strings.Contains(args[0].(*object.String).Value, sub.Value))
+			},
+		},
+	),
+	object.INTEGER_OBJ: methods(
+		&object.Builtin{
+			Name:  "abs",
+			Arity: 1,
+			Doc:   "n.abs() returns the absolute value of n.",
+			Fn: func(args ...object.Object) object.Object {
+				n := args[0].(*object.Integer).Value
+				if n < 0 {
+					n = -n
+				}
+				return &object.Integer{Value: n}
+			},
+		},
+	),
+	object.ARRAY_OBJ: methods(
+		&object.Builtin{
+			Name:  "len",
+			Arity: 1,
+			Doc:   "a.len() returns the number of elements in a.",
+			Fn: func(args ...object.Object) object.Object {
+				return &object.Integer{Value: int64(len(args[0].(*object.Array).Elements))}
+			},
+		},
+		&object.Builtin{
+			Name:  "join",
+			Arity: 2,
+			Doc:   "a.join(sep) returns the elements of a printed and separated by sep.",
+			Fn: func(args ...object.Object) object.Object {
+				sep, ok := args[1].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument to `join` must be STRING, got %s", args[1].Type())
+				}
+				parts := []string{}
+				for _, el := range args[0].(*object.Array).Elements {
+					parts = append(parts, el.Inspect())
+				}
+				return &object.String{Value: strings.Join(parts, sep.Value)}
+			},
+		},
+		&object.Builtin{
+			Name:  "contains",
+			Arity: 2,
+			Doc:   "a.contains(x) reports whether an element of a is equal to x.",
+			Fn: func(args ...object.Object) object.Object {
+				for _, el := range args[0].(*object.Array).Elements {
+					if valuesEqual(el, args[1], 0) {
+						return TRUE
+					}
+				}
+				return FALSE
+			},
+		},
+	),
+}
+
+func methods(list ...*object.Builtin) map[string]*object.Builtin {
+	table := map[string]*object.Builtin{}
+	for _, b := range list {
+		table[b.Name] = b
+	}
+	return table
+}
+
+// findMethod returns the method name of values named typ, of builtin type
+// base: the one added by an extend visible from env, or else the builtin one.
+func findMethod(env *object.Environment, typ, base object.ObjectType, name string) (object.Object, bool) {
+	if method, ok := env.Get(object.MethodName(typ, name)); ok {
+		return method, true
+	}
+	if method, ok := builtinMethods[base][name]; ok {
+		return method, true
+	}
+	return nil, false
+}
+
+// missingMethod returns the first method of trait that values named typ, of builtin type base, lack, or "".
+func missingMethod(env *object.Environment, typ, base object.ObjectType, trait *object.Trait) string {
+	for _, name := range trait.Methods {
+		if _, ok := findMethod(env, typ, base, name); !ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// evalMethodCall calls recv.name(args...): the member name of recv if it
+// has one, such as a struct field holding a function, or else the method
+// name of the type of recv, given recv as its first argument.
+func (e *Evaluator) evalMethodCall(member *ast.MemberExpression, arguments []ast.Expression, env *object.Environment) object.Object {
+	recv := e.Eval(member.Object, env)
+	if unwinds(recv) {
+		return recv
+	}
+	args := e.evalExpressions(arguments, env)
+	if len(args) == 1 && unwinds(args[0]) {
+		return args[0]
+	}
+
+	name := member.Property.Value
+	if getter, ok := recv.(object.MemberGetter); ok {
+		if fn, ok := getter.GetMember(name); ok {
+			return e.applyFunction(fn, args)
+		}
+	}
+
+	typ := object.TypeName(recv)
+	method, ok := findMethod(env, typ, recv.Type(), name)
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s has no method `%s`", typ, name)
+	}
+	if want := methodArity(method); want != object.VARIADIC && len(args) != want {
+		return newError(object.ARITY_ERROR, "wrong number of arguments to %s.%s. got=%d, want=%d", typ, name, len(args), want)
+	}
+	return e.applyFunction(method, append([]object.Object{recv}, args...))
+}
+
+// methodArity returns the number of arguments method takes besides the receiver.
+func methodArity(method object.Object) int {
+	switch method := method.(type) {
+	case *object.Function:
+		return len(method.Parameters) - 1
+	case *object.Builtin:
+		if method.Arity != object.VARIADIC {
+			return method.Arity - 1
+		}
+	}
+	return object.VARIADIC
+}
+
+func (e *Evaluator) evalExtendStatement(node *ast.ExtendStatement, env *object.Environment) object.Object {
+	typ := object.ObjectType(node.Type.Value)
+	base := typ
+	if !extendable[node.Type.Value] {
+		obj, _ := env.Get(node.Type.Value)
+		switch obj.(type) {
+		case *object.StructType, *object.EnumType:
+			base = obj.Type()
+		default:
+			return newError(object.TYPE_ERROR, "cannot extend %s, it is not a type", node.Type.Value)
+		}
+	}
+
+	// the methods added here count towards the traits
+	scope := object.NewEnclosedEnvironment(env)
+	for _, m := range node.Methods {
+		scope.Set(object.MethodName(typ, m.Name.Value), NULL)
+	}
+	for _, ident := range node.Traits {
+		obj, _ := env.Get(ident.Value)
+		trait, ok := obj.(*object.Trait)
+		if !ok {
+			return newError(object.TYPE_ERROR, "%s is not a trait", ident.Value)
+		}
+		if missing := missingMethod(scope, typ, base, trait); missing != "" {
+			return newError(object.TYPE_ERROR, "%s does not implement %s: missing method %s", typ, trait.Name, missing)
+		}
+	}
+
+	for _, m := range node.Methods {
+		method := e.account(evalFunctionLiteral(m, env))
+		if isError(method) {
+			return method
+		}
+		if bound := env.Set(object.MethodName(typ, m.Name.Value), method); isError(bound) {
+			return bound
+		}
+	}
+	return nil
+}
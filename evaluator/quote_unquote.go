@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"fmt"
+	"morty/ast"
+	"morty/object"
+	"morty/token"
+)
+
+func isQuoteCall(node *ast.CallExpression) bool {
+	ident, ok := node.Function.(*ast.Identifier)
+	return ok && ident.Value == "quote"
+}
+
+func isUnquoteCall(node ast.Noder) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// quote returns its argument unevaluated, except for the unquote(expr) calls
+// inside it, which are replaced by the code for the value of expr. The
+// argument is copied first, since every call of the enclosing function or
+// macro quotes the same node.
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(object.ARITY_ERROR, "wrong number of arguments. got=%d, want=1", len(call.Arguments))
+	}
+
+	var failure object.Object
+	node := ast.Modify(ast.Copy(call.Arguments[0]), func(node ast.Noder) ast.Noder {
+		if failure != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		unquote := node.(*ast.CallExpression)
+		if len(unquote.Arguments) != 1 {
+			failure = newError(object.ARITY_ERROR, "wrong number of arguments. got=%d, want=1", len(unquote.Arguments))
+			return node
+		}
+
+		unquoted := e.Eval(unquote.Arguments[0], env)
+		if unwinds(unquoted) {
+			failure = unquoted
+			return node
+		}
+
+		converted, err := convertObjectToASTNode(unquoted)
+		if err != nil {
+			failure = err
+			return node
+		}
+		return converted
+	})
+	if failure != nil {
+		return failure
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func convertObjectToASTNode(obj object.Object) (ast.Expression, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false"}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+		array.Elements = []ast.Expression{}
+		for _, el := range obj.Elements {
+			element, err := convertObjectToASTNode(el)
+			if err != nil {
+				return nil, err
+			}
+			array.Elements = append(array.Elements, element)
+		}
+		return array, nil
+
+	case *object.Quote:
+		exp, ok := obj.Node.(ast.Expression)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "cannot unquote statement %s", obj.Node.ToString())
+		}
+		return exp, nil
+
+	default:
+		return nil, newError(object.TYPE_ERROR, "cannot unquote %s", obj.Type())
+	}
+}
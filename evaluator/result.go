@@ -0,0 +1,79 @@
+package evaluator
+
+import "morty/object"
+
+// Results carry errors as ordinary values for library code that prefers
+// them to exceptions. The ? operator unwraps ok(v) to v and returns err(e)
+// from the enclosing function.
+var resultBuiltins = []*object.Builtin{
+	{
+		Name:  "ok",
+		Arity: 1,
+		Doc:   "ok(v) returns a successful result holding v.",
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Result{Ok: true, Value: args[0]}
+		},
+	},
+	{
+		Name:  "err",
+		Arity: 1,
+		Doc:   "err(e) returns a failed result holding e.",
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Result{Ok: false, Value: args[0]}
+		},
+	},
+	{
+		Name:  "is_ok",
+		Arity: 1,
+		Doc:   "is_ok(r) reports whether the result r is ok.",
+		Fn: func(args ...object.Object) object.Object {
+			result, ok := args[0].(*object.Result)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `is_ok` must be RESULT, got %s", args[0].Type())
+			}
+			return ToBoolObject(result.Ok)
+		},
+	},
+	{
+		Name:  "is_err",
+		Arity: 1,
+		Doc:   "is_err(r) reports whether the result r is an err.",
+		Fn: func(args ...object.Object) object.Object {
+			result, ok := args[0].(*object.Result)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `is_err` must be RESULT, got %s", args[0].Type())
+			}
+			return ToBoolObject(!result.Ok)
+		},
+	},
+	{
+		Name:  "unwrap",
+		Arity: 1,
+		Doc:   "unwrap(r) returns the value of the ok result r, and throws the error of an err.",
+		Fn: func(args ...object.Object) object.Object {
+			result, ok := args[0].(*object.Result)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `unwrap` must be RESULT, got %s", args[0].Type())
+			}
+			if !result.Ok {
+				return newThrownError(result.Value)
+			}
+			return result.Value
+		},
+	},
+	{
+		Name:  "unwrap_or",
+		Arity: 2,
+		Doc:   "unwrap_or(r, default) returns the value of the ok result r, or default if r is an err.",
+		Fn: func(args ...object.Object) object.Object {
+			result, ok := args[0].(*object.Result)
+			if !ok {
+				return newError(object.TYPE_ERROR, "first argument to `unwrap_or` must be RESULT, got %s", args[0].Type())
+			}
+			if !result.Ok {
+				return args[1]
+			}
+			return result.Value
+		},
+	},
+}
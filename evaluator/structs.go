@@ -0,0 +1,109 @@
+package evaluator
+
+import (
+	"morty/ast"
+	"morty/object"
+)
+
+func (e *Evaluator) evalStructLiteral(node *ast.StructLiteral, env *object.Environment) object.Object {
+	obj := e.Eval(node.Name, env)
+	if unwinds(obj) {
+		return obj
+	}
+	def, ok := obj.(*object.StructType)
+	if !ok {
+		return newError(object.TYPE_ERROR, "%s is not a struct, got %s", node.Name.Value, obj.Type())
+	}
+
+	values := make([]object.Object, len(def.Fields))
+	for i, field := range node.Fields {
+		idx := def.Field(field.Value)
+		if idx < 0 {
+			return newError(object.TYPE_ERROR, "%s has no member `%s`", def.Name, field.Value)
+		}
+		if values[idx] != nil {
+			return newError(object.TYPE_ERROR, "field %s of %s given twice", field.Value, def.Name)
+		}
+		val := e.Eval(node.Values[i], env)
+		if unwinds(val) {
+			return val
+		}
+		values[idx] = val
+	}
+	for i, val := range values {
+		if val == nil {
+			return newError(object.TYPE_ERROR, "missing field %s of %s", def.Fields[i], def.Name)
+		}
+	}
+
+	return &object.Struct{Def: def, Values: values}
+}
+
+// maxEqualDepth bounds the comparison of structs reaching themselves.
+const maxEqualDepth = 100
+
+// equal reports whether a == b: structs of the same type and values of the
+// same enum variant are equal when their fields are, other objects when they
+// are the same object.
+func equal(a, b object.Object, depth int) bool {
+	if av, ok := a.(*object.EnumValue); ok {
+		bv, ok := b.(*object.EnumValue)
+		if !ok || av.Variant != bv.Variant || depth >= maxEqualDepth {
+			return a == b
+		}
+		for i := range av.Values {
+			if !valuesEqual(av.Values[i], bv.Values[i], depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+
+	as, ok := a.(*object.Struct)
+	if !ok {
+		return a == b
+	}
+	bs, ok := b.(*object.Struct)
+	if !ok || as.Def != bs.Def {
+		return false
+	}
+	if as == bs || depth >= maxEqualDepth {
+		return as == bs
+	}
+
+	av, bv := as.Fields(), bs.Fields()
+	for i := range av {
+		if !valuesEqual(av[i], bv[i], depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// valuesEqual compares field values the way == compares them, except that
+// arrays are equal when their elements are, down to maxEqualDepth.
+func valuesEqual(a, b object.Object, depth int) bool {
+	switch a := a.(type) {
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || depth >= maxEqualDepth {
+			return a == b
+		}
+		for i := range a.Elements {
+			if !valuesEqual(a.Elements[i], b.Elements[i], depth+1) {
+				return false
+			}
+		}
+		return true
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	}
+	return equal(a, b, depth)
+}
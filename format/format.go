@@ -0,0 +1,21 @@
+// Package format formats Morty source in the canonical style of the printer package.
+package format
+
+import (
+	"fmt"
+	"morty/lexer"
+	"morty/parser"
+	"morty/printer"
+	"strings"
+)
+
+// Source returns src formatted, keeping its comments. It fails if src does not parse.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse error: %s", strings.Join(p.Errors(), "; "))
+	}
+
+	return []byte(printer.Sprint(program)), nil
+}
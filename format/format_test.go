@@ -0,0 +1,68 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let   x=5;let y = x*2",
+			"let x = 5;\nlet y = x * 2;\n",
+		},
+		{
+			"// adds numbers\nlet add = fn(a,b){\n  // the sum\n  a+b // no return needed\n}; // done\n",
+			"// adds numbers\nlet add = fn(a, b) {\n\t// the sum\n\ta + b; // no return needed\n}; // done\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"if (x) {\n\n  // nothing yet\n}\n// trailing comment at the end\n",
+			"if (x) {\n\t// nothing yet\n};\n// trailing comment at the end\n",
+		},
+		{
+			"if (x) { // why\n  1 } else { 2 }",
+			"if (x) { // why\n\t1;\n} else {\n\t2;\n};\n",
+		},
+		{
+			`puts(first_argument_value, second_argument_value, third_argument_value, fourth_one);`,
+			"puts(\n\tfirst_argument_value,\n\tsecond_argument_value,\n\tthird_argument_value,\n\tfourth_one\n);\n",
+		},
+		{
+			`let f = fn() { return compute(first_argument_value, second_argument_value, third_argument_value); }`,
+			"let f = fn() {\n\treturn compute(\n\t\tfirst_argument_value,\n\t\tsecond_argument_value,\n\t\tthird_argument_value\n\t);\n};\n",
+		},
+		{"", ""},
+		{"// only a comment", "// only a comment\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("wrong formatting of %q\nexpected=%q\ngot=     %q", tt.input, tt.expected, formatted)
+			continue
+		}
+
+		again, err := Source(formatted)
+		if err != nil || string(again) != string(formatted) {
+			t.Errorf("formatting %q is not idempotent, got %q", formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source([]byte("let = 5;"))
+	if err == nil || !strings.HasPrefix(err.Error(), "parse error: ") {
+		t.Errorf("expected parse error, got=%v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Package morty embeds the Morty interpreter in Go programs.
+package morty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/lexer"
+	"morty/object"
+	"morty/parser"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Interpreter runs Morty source against a persistent global environment.
+type Interpreter struct {
+	env      *object.Environment
+	macros   *object.Environment
+	builtins *evaluator.Registry
+	out      io.Writer
+	limits   evaluator.Limits
+	caps     []string
+}
+
+type Option func(*Interpreter)
+
+// WithEnvironment makes the interpreter use env for its globals.
+func WithEnvironment(env *object.Environment) Option {
+	return func(i *Interpreter) {
+		i.env = env
+	}
+}
+
+// WithBuiltins makes the interpreter resolve builtins from r instead of a fresh standard registry.
+func WithBuiltins(r *evaluator.Registry) Option {
+	return func(i *Interpreter) {
+		i.builtins = r
+	}
+}
+
+// WithOutput sets the writer builtins such as puts print to. It defaults to os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.out = w
+	}
+}
+
+// WithLimits bounds the steps, allocations and output of every Run and Call.
+func WithLimits(l evaluator.Limits) Option {
+	return func(i *Interpreter) {
+		i.limits = l
+	}
+}
+
+// WithCapabilities grants scripts the builtins guarded by caps, such as evaluator.CAP_FS_READ.
+// Without it scripts only see builtins that need no capability.
+func WithCapabilities(caps ...string) Option {
+	return func(i *Interpreter) {
+		i.caps = append(i.caps, caps...)
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment(), macros: object.NewEnvironment(), builtins: evaluator.NewRegistry(), out: os.Stdout}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// ParseError is returned by Run when the source does not parse.
+type ParseError struct {
+	Messages    []string
+	Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Messages, "; ")
+}
+
+// RuntimeError is returned by Run when evaluation produces an error object.
+// A RuntimeError of kind object.CANCELLED_ERROR unwraps to the error of the
+// context that stopped the evaluation.
+type RuntimeError struct {
+	Kind       string
+	Message    string
+	Diagnostic diagnostic.Diagnostic // points at the failing expression of the source
+	cause      error
+}
+
+func (e *RuntimeError) Error() string {
+	return "runtime error: " + e.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.cause
+}
+
+// Run parses source, expands its macros and evaluates it, returning the value of the
+// last statement converted with object.ToGo. Macros stay defined for later runs.
+func (i *Interpreter) Run(ctx context.Context, source string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the tasks left running
+
+	l := lexer.New(source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors(), Diagnostics: p.Diagnostics()}
+	}
+
+	if err := evaluator.DefineMacros(program, i.macros); err != nil {
+		return result(ctx, err)
+	}
+	eval := i.evaluator(ctx)
+	expanded, err := eval.ExpandMacros(program, i.macros)
+	if err != nil {
+		return result(ctx, err)
+	}
+
+	return result(ctx, eval.Eval(expanded, i.env))
+}
+
+// Call looks up the function bound to name and applies it to args, converted with object.FromGo.
+func (i *Interpreter) Call(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		if fn, ok = i.builtins.Lookup(name); !ok {
+			return nil, fmt.Errorf("identifier not found: %s", name)
+		}
+	}
+	return i.CallValue(ctx, fn, args...)
+}
+
+// CallValue applies the function or builtin fn to args, converted with object.FromGo.
+func (i *Interpreter) CallValue(ctx context.Context, fn object.Object, args ...interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the tasks left running
+
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("not a function: %s", fn.Type())
+	}
+
+	objs := make([]object.Object, 0, len(args))
+	for idx, arg := range args {
+		obj, err := object.FromGo(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", idx+1, err)
+		}
+		objs = append(objs, obj)
+	}
+
+	return result(ctx, i.evaluator(ctx).Call(fn, objs...))
+}
+
+func (i *Interpreter) evaluator(ctx context.Context) *evaluator.Evaluator {
+	return evaluator.New(
+		evaluator.WithContext(ctx),
+		evaluator.WithBuiltins(i.builtins),
+		evaluator.WithOutput(i.out),
+		evaluator.WithLimits(i.limits),
+		evaluator.WithCapabilities(i.caps...),
+	)
+}
+
+func result(ctx context.Context, evaluated object.Object) (interface{}, error) {
+	if errObj, ok := evaluated.(*object.Error); ok {
+		err := &RuntimeError{Kind: errObj.Kind, Message: errObj.Message, Diagnostic: diagnostic.FromError(errObj)}
+		if errObj.Kind == object.CANCELLED_ERROR {
+			err.cause = ctx.Err()
+		}
+		return nil, err
+	}
+	return object.ToGo(evaluated), nil
+}
+
+// Get returns the global bound to name converted with object.ToGo.
+func (i *Interpreter) Get(name string) (interface{}, bool) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return object.ToGo(obj), true
+}
+
+// GetInto stores the global bound to name in the value pointed to by ptr.
+func (i *Interpreter) GetInto(name string, ptr interface{}) error {
+	dst := reflect.ValueOf(ptr)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return fmt.Errorf("GetInto: expected a non-nil pointer, got %T", ptr)
+	}
+
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return fmt.Errorf("identifier not found: %s", name)
+	}
+
+	val, err := object.ConvertTo(obj, dst.Elem().Type())
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	dst.Elem().Set(val)
+	return nil
+}
+
+// Set binds name to value in the global environment. value may be a Go value or an object.Object.
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return bind(i.env, name, obj)
+}
+
+// Expose binds name to the Go value v, making the fields and methods listed in members
+// reachable through dot syntax. See object.NewNative.
+func (i *Interpreter) Expose(name string, v interface{}, members ...string) error {
+	native, err := object.NewNative(v, members...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return bind(i.env, name, native)
+}
+
+func bind(env *object.Environment, name string, obj object.Object) error {
+	if errObj, ok := env.Set(name, obj).(*object.Error); ok {
+		return errors.New(errObj.Message)
+	}
+	return nil
+}
+
+// Fork freezes the globals of i and returns an interpreter whose globals are
+// a fresh scope enclosed by them. Forks share builtins and options with i and
+// can run concurrently with each other; bindings made by a fork stay private
+// to it. Values bound before the fork are shared, not copied: a struct set
+// by one fork is seen set by the others, its fields guarded by a lock. Go
+// values exposed through Expose are shared too and must do their own locking.
+func (i *Interpreter) Fork() *Interpreter {
+	i.env.Freeze()
+	i.macros.Freeze()
+
+	fork := *i
+	fork.env = object.NewEnclosedEnvironment(i.env)
+	fork.macros = object.NewEnclosedEnvironment(i.macros)
+	return &fork
+}
+
+// Register adds b to the builtins of this interpreter.
+func (i *Interpreter) Register(b *object.Builtin) error {
+	return i.builtins.Register(b)
+}
+
+// RegisterFunc wraps the Go function fn with object.WrapFunc and registers it as name.
+func (i *Interpreter) RegisterFunc(name, doc string, fn interface{}) error {
+	b, err := object.WrapFunc(name, doc, fn)
+	if err != nil {
+		return err
+	}
+	return i.builtins.Register(b)
+}
+
+// Builtins returns the builtin registry of this interpreter.
+func (i *Interpreter) Builtins() *evaluator.Registry {
+	return i.builtins
+}
+
+// Environment returns the global environment of the interpreter.
+func (i *Interpreter) Environment() *object.Environment {
+	return i.env
+}
@@ -0,0 +1,609 @@
+package morty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/object"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"5 + 5", int64(10)},
+		{`"Hello" + " " + "World"`, "Hello World"},
+		{"1 < 2", true},
+		{"if (false) { 1 }", nil},
+		{"let add = fn(a, b) { a + b }; add(2, 3)", int64(5)},
+	}
+
+	for _, tt := range tests {
+		interp := New()
+		result, err := interp.Run(context.Background(), tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Run(%q) wrong result, expected=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestRunCyclicStruct(t *testing.T) {
+	result, err := New().Run(context.Background(), "struct Node { next } let n = Node{next: 0}; n.next = n; n")
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	expected := map[string]interface{}{"next": "Node{next: Node{...}}"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result, expected=%#v, got=%#v", expected, result)
+	}
+}
+
+func TestRunKeepsGlobals(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if _, err := interp.Run(ctx, "let x = 40;"); err != nil {
+		t.Fatalf("first Run returned error: %s", err)
+	}
+	result, err := interp.Run(ctx, "x + 2")
+	if err != nil {
+		t.Fatalf("second Run returned error: %s", err)
+	}
+	if result != int64(42) {
+		t.Errorf("wrong result, expected=42, got=%#v", result)
+	}
+}
+
+func TestRunMacros(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	unless := `let unless = macro(cond, cons, alt) {
+		quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) })
+	};`
+	if _, err := interp.Run(ctx, unless); err != nil {
+		t.Fatalf("defining macro returned error: %s", err)
+	}
+
+	result, err := interp.Run(ctx, `unless(10 > 5, "not greater", "greater")`)
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if result != "greater" {
+		t.Errorf("wrong result, expected=%q, got=%#v", "greater", result)
+	}
+
+	// every call expands the macro anew, in this run or a later one
+	result, err = interp.Run(ctx, `unless(1 > 5, "not greater", "greater")`)
+	if err != nil || result != "not greater" {
+		t.Errorf("wrong result, expected=%q, got=%#v (%v)", "not greater", result, err)
+	}
+
+	_, err = interp.Run(ctx, "unless(true, 1)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind != object.ARITY_ERROR {
+		t.Errorf("expected ArityError, got=%v", err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	_, err := interp.Run(ctx, "let = 5;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError, got=%T (%v)", err, err)
+	}
+	if len(parseErr.Messages) == 0 {
+		t.Errorf("ParseError has no messages")
+	}
+	if len(parseErr.Diagnostics) != 1 || parseErr.Diagnostics[0].Code != diagnostic.UnexpectedToken {
+		t.Errorf("wrong diagnostics %+v", parseErr.Diagnostics)
+	}
+
+	_, err = interp.Run(ctx, "5 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message, got=%q", runtimeErr.Message)
+	}
+	if d := runtimeErr.Diagnostic; d.Code != diagnostic.TypeError || d.Primary.Span.Start.Column != 1 || d.Primary.Span.End.Column != 9 {
+		t.Errorf("wrong diagnostic %+v", d)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := interp.Run(cancelled, "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got=%v", err)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if err := interp.Set("name", "Morty"); err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+	if err := interp.Set("age", 14); err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+	if err := interp.Set("bad", struct{}{}); err == nil {
+		t.Errorf("expected Set to reject struct value")
+	}
+	if err := interp.Set("big", uint64(math.MaxUint64)); err == nil {
+		t.Errorf("expected Set to reject a uint64 overflowing int64")
+	}
+	if err := interp.Set("max", uint64(math.MaxInt64)); err != nil {
+		t.Errorf("Set returned error: %s", err)
+	}
+
+	if _, err := interp.Run(ctx, `let greeting = "Hi " + name; let older = age + 1;`); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	greeting, ok := interp.Get("greeting")
+	if !ok || greeting != "Hi Morty" {
+		t.Errorf("wrong greeting, got=%#v (%t)", greeting, ok)
+	}
+
+	var older int
+	if err := interp.GetInto("older", &older); err != nil {
+		t.Fatalf("GetInto returned error: %s", err)
+	}
+	if older != 15 {
+		t.Errorf("wrong older, expected=15, got=%d", older)
+	}
+
+	var wrong bool
+	if err := interp.GetInto("older", &wrong); err == nil {
+		t.Errorf("expected GetInto to reject INTEGER into bool")
+	}
+
+	if _, ok := interp.Get("missing"); ok {
+		t.Errorf("expected missing global to be absent")
+	}
+}
+
+func TestSetBooleanIsTruthy(t *testing.T) {
+	interp := New()
+	interp.Set("flag", false)
+
+	result, err := interp.Run(context.Background(), "if (flag) { 1 } else { 2 }")
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if result != int64(2) {
+		t.Errorf("wrong result, expected=2, got=%#v", result)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	interp := New(WithCapabilities(evaluator.CAP_TASKS))
+	ctx := context.Background()
+	interp.RegisterFunc("boom", "", func() int { panic("boom") })
+
+	_, err := interp.Run(ctx, "wait(spawn(boom))")
+	if err == nil || err.Error() != "runtime error: task panicked: boom" {
+		t.Errorf("wrong error, got=%v", err)
+	}
+
+	// a task still running when Run returns is cancelled
+	if _, err := interp.Run(ctx, "let task = spawn(recv, channel())"); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	obj, _ := interp.Environment().Get("task")
+	task := obj.(*object.Task)
+	select {
+	case <-task.Done():
+		if errObj, ok := task.Result().(*object.Error); !ok || errObj.Kind != object.CANCELLED_ERROR {
+			t.Errorf("expected CancelledError, got=%v", task.Result())
+		}
+	case <-time.After(time.Second):
+		t.Errorf("task outlived Run")
+	}
+
+	// tasks set the fields of a struct they share with the script
+	result, err := interp.Run(ctx, "struct C { n } let c = C{n: 0}; let t = spawn(fn() { c.n = 1 }); c.n = 2; wait(t); c.n")
+	if err != nil || (result != int64(1) && result != int64(2)) {
+		t.Errorf("wrong result, got=%#v (%v)", result, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	err := interp.RegisterFunc("repeat", "repeat(s, n) repeats s n times.", func(s string, n int64) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, int(n)), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunc returned error: %s", err)
+	}
+	interp.RegisterFunc("sum", "", func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum()`, int64(0)},
+		{`sum(1, 2, 3)`, int64(6)},
+	}
+	for _, tt := range tests {
+		result, err := interp.Run(ctx, tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Run(%q) wrong result, expected=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", -1)`, "repeat: negative count"},
+		{`repeat(1, 2)`, "repeat: argument 1: cannot convert INTEGER to string"},
+		{`repeat("ab")`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range errorTests {
+		_, err := interp.Run(ctx, tt.input)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("Run(%q) expected *RuntimeError, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if runtimeErr.Message != tt.expected {
+			t.Errorf("wrong error message, expected=%q, got=%q", tt.expected, runtimeErr.Message)
+		}
+	}
+
+	if _, err := New().Run(ctx, `repeat("ab", 3)`); err == nil {
+		t.Errorf("expected builtin to be private to the interpreter it was registered on")
+	}
+
+	b, ok := interp.Builtins().Lookup("repeat")
+	if !ok || b.Arity != 2 || b.Doc != "repeat(s, n) repeats s n times." {
+		t.Errorf("wrong builtin metadata, got=%+v", b)
+	}
+
+	if err := interp.RegisterFunc("bad", "", 42); err == nil {
+		t.Errorf("expected RegisterFunc to reject non-function")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	_, err := interp.Run(ctx, `
+	let seen = "";
+	fn on_event(e) { "handled " + e }
+	let add = fn(a, b) { a + b };`)
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	result, err := interp.Call(ctx, "on_event", "click")
+	if err != nil {
+		t.Fatalf("Call returned error: %s", err)
+	}
+	if result != "handled click" {
+		t.Errorf("wrong result, got=%#v", result)
+	}
+
+	result, err = interp.Call(ctx, "add", 2, 3)
+	if err != nil || result != int64(5) {
+		t.Errorf("wrong result, got=%#v (%v)", result, err)
+	}
+
+	result, err = interp.Call(ctx, "len", "four")
+	if err != nil || result != int64(4) {
+		t.Errorf("wrong result, got=%#v (%v)", result, err)
+	}
+
+	if _, err := interp.Call(ctx, "add", 1); err == nil || err.Error() != "runtime error: wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong error, got=%v", err)
+	}
+	if _, err := interp.Call(ctx, "seen"); err == nil || err.Error() != "not a function: STRING" {
+		t.Errorf("wrong error, got=%v", err)
+	}
+	if _, err := interp.Call(ctx, "missing"); err == nil {
+		t.Errorf("expected error for missing function")
+	}
+
+	// the host calling a builtin by a name it was handed gets no more than scripts
+	denied := "runtime error: permission denied: `read_file` requires capability fs.read"
+	if _, err := interp.Call(ctx, "read_file", "go.mod"); err == nil || err.Error() != denied {
+		t.Errorf("wrong error, got=%v", err)
+	}
+	readFile, _ := evaluator.NewRegistry().Lookup("read_file")
+	if _, err := interp.CallValue(ctx, readFile, "go.mod"); err == nil || err.Error() != denied {
+		t.Errorf("wrong error, got=%v", err)
+	}
+}
+
+func TestCallChecksAnnotations(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if _, err := interp.Run(ctx, `fn scale(x: int, by: int) -> int { x * by }`); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	result, err := interp.Call(ctx, "scale", 2, 3)
+	if err != nil || result != int64(6) {
+		t.Errorf("wrong result, got=%#v (%v)", result, err)
+	}
+
+	_, err = interp.Call(ctx, "scale", 2, "3")
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) || rerr.Kind != object.TYPE_ERROR || rerr.Message != "fn scale: parameter by must be int, got STRING" {
+		t.Errorf("wrong error, got=%v", err)
+	}
+}
+
+func TestHigherOrderBuiltin(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	err := interp.RegisterFunc("twice", "twice(f, x) applies f to x twice.", func(rt object.Runtime, f object.Object, x object.Object) object.Object {
+		return rt.Call(f, rt.Call(f, x))
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunc returned error: %s", err)
+	}
+
+	result, err := interp.Run(ctx, `twice(fn(x) { x * 3 }, 2)`)
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if result != int64(18) {
+		t.Errorf("wrong result, expected=18, got=%#v", result)
+	}
+
+	b, _ := interp.Builtins().Lookup("twice")
+	if b.Arity != 2 {
+		t.Errorf("runtime parameter counted in arity, got=%d", b.Arity)
+	}
+}
+
+type testUser struct {
+	Name      string
+	Age       int
+	FirstName string `morty:"first"`
+	Password  string
+}
+
+func (u *testUser) Greet(greeting string) string {
+	return greeting + ", " + u.Name
+}
+
+func (u *testUser) Birthday() {
+	u.Age++
+}
+
+func TestExpose(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+	user := &testUser{Name: "Morty", Age: 14, FirstName: "Mort", Password: "hunter2"}
+
+	if err := interp.Expose("user", user, "Name", "Age", "FirstName", "Greet", "Birthday"); err != nil {
+		t.Fatalf("Expose returned error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`user.name`, "Morty"},
+		{`user.first`, "Mort"},
+		{`user.greet("Hi")`, "Hi, Morty"},
+		{`user.birthday(); user.age`, int64(15)},
+		{`user.name = "Rick"; user.greet("Yo")`, "Yo, Rick"},
+	}
+	for _, tt := range tests {
+		result, err := interp.Run(ctx, tt.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Run(%q) wrong result, expected=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	if user.Name != "Rick" || user.Age != 15 {
+		t.Errorf("writes not visible to Go, got=%+v", user)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`user.password`, "NATIVE has no member `password`"},
+		{`user.age = "old"`, "member `age`: cannot convert STRING to int"},
+		{`user.greet = 1`, "NATIVE has no member `greet`"},
+		{`5.name`, "INTEGER has no member `name`"},
+	}
+	for _, tt := range errorTests {
+		_, err := interp.Run(ctx, tt.input)
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("Run(%q) expected *RuntimeError, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if runtimeErr.Message != tt.expected {
+			t.Errorf("wrong error message, expected=%q, got=%q", tt.expected, runtimeErr.Message)
+		}
+	}
+
+	got, _ := interp.Get("user")
+	if got != user {
+		t.Errorf("Get did not return the wrapped value, got=%#v", got)
+	}
+
+	if err := interp.Expose("bad", user, "Nope"); err == nil {
+		t.Errorf("expected Expose to reject unknown member")
+	}
+	if err := interp.Expose("bad", user, "password"); err == nil {
+		t.Errorf("expected Expose to reject unexported member")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	interp := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := interp.Run(ctx, `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(50);`)
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+	if runtimeErr.Kind != object.CANCELLED_ERROR {
+		t.Errorf("wrong error kind, got=%q", runtimeErr.Kind)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got=%v", err)
+	}
+
+	result, err := interp.Run(context.Background(), "fib(10)")
+	if err != nil || result != int64(55) {
+		t.Errorf("interpreter unusable after timeout, got=%#v (%v)", result, err)
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	var out bytes.Buffer
+	interp := New(WithOutput(&out), WithLimits(evaluator.Limits{MaxSteps: 500}))
+	ctx := context.Background()
+
+	if _, err := interp.Run(ctx, `puts("hi")`); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("wrong output, got=%q", out.String())
+	}
+
+	_, err := interp.Run(ctx, "let f = fn() { f() }; f()")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+	if runtimeErr.Kind != object.STEP_LIMIT_ERROR {
+		t.Errorf("wrong error kind, got=%q", runtimeErr.Kind)
+	}
+
+	if _, err := interp.Run(ctx, "1 + 1"); err != nil {
+		t.Errorf("budget not reset between runs, got=%v", err)
+	}
+}
+
+func TestFork(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+
+	if _, err := interp.Run(ctx, `let greet = fn(name) { "Hello " + name };`); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fork := interp.Fork()
+			fork.Set("n", i)
+
+			result, err := fork.Run(ctx, `let name = "worker"; greet(name)`)
+			if err != nil || result != "Hello worker" {
+				t.Errorf("wrong result, got=%#v (%v)", result, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, ok := interp.Get("name"); ok {
+		t.Errorf("binding made by a fork leaked into the parent")
+	}
+	if err := interp.Set("late", 1); err == nil {
+		t.Errorf("expected Set on a forked parent to fail")
+	}
+}
+
+func TestForkSharesStructs(t *testing.T) {
+	interp := New()
+	ctx := context.Background()
+	if _, err := interp.Run(ctx, "struct C { n } let c = C{n: 0};"); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fork := interp.Fork()
+			fork.Set("i", i)
+			if _, err := fork.Run(ctx, "c.n = i; c == C{n: i}"); err != nil {
+				t.Errorf("Run returned error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestInspectSharedStructs(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run(context.Background(), "struct P { x, y } let p = P{x: [1], y: P{x: 2, y: 3}};"); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	p, _ := interp.Environment().Get("p")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if got := p.Inspect(); got != "P{x: [1], y: P{x: 2, y: 3}}" {
+					t.Errorf("wrong result, got=%q", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
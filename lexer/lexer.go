@@ -1,27 +1,50 @@
 package lexer
 
-import "morty/token"
+import (
+	"morty/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // current position in input (current character)
 	readPosition int  // current reading position in input (after current character)
 	ch           byte // current character under examination
+	line         int  // line of the current character
+	lineStart    int  // position of the first character of the current line
+	comments     []token.Token
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// Comments returns the // comments skipped so far, in source order.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.position - l.lineStart + 1}
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
+
 	if l.readPosition >= len(l.input) {
+		// stay at the end, so positions never point past the input
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.position = len(l.input)
+		l.readPosition = len(l.input) + 1
+		return
 	}
 
+	l.ch = l.input[l.readPosition]
 	l.position = l.readPosition
 	l.readPosition++
 }
@@ -30,6 +53,7 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := l.pos()
 
 	switch l.ch {
 	case '=':
@@ -42,7 +66,12 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "->"}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -56,6 +85,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -68,6 +99,12 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '|':
+		tok = newToken(token.PIPE, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '{':
@@ -88,10 +125,12 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -99,6 +138,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -120,9 +160,25 @@ func (l *Lexer) readNumber() string {
 }
 
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) skipComment() {
+	pos := l.pos()
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	literal := strings.TrimRight(l.input[pos.Offset:l.position], " \t\r")
+	l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: literal, Pos: pos})
 }
 
 func (l *Lexer) peekChar() byte {
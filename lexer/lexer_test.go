@@ -25,6 +25,10 @@ func TestNextToken(t *testing.T) {
 	"foobar"
 	"foo bar"
 	[1, 2];
+	user.name = "x";
+	fn(a: int) -> int | null
+	struct P { x }
+	match (s) { case _ if y {} }
 	`
 
 	tests := []struct {
@@ -112,6 +116,39 @@ func TestNextToken(t *testing.T) {
 		{token.INT, "2"},
 		{token.RBRACKET, "]"},
 		{token.SEMICOLON, ";"},
+		{token.IDENT, "user"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.ASSIGN, "="},
+		{token.STRING, "x"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.PIPE, "|"},
+		{token.IDENT, "null"},
+		{token.STRUCT, "struct"},
+		{token.IDENT, "P"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "s"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.CASE, "case"},
+		{token.IDENT, "_"},
+		{token.IF, "if"},
+		{token.IDENT, "y"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 
@@ -127,3 +164,45 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestCommentsAndPositions(t *testing.T) {
+	input := "// header\nlet x = 10 / 2; // half\n  \"a\nb\" y"
+
+	l := New(input)
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Offset: 10, Line: 2, Column: 1}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 5}},
+		{token.ASSIGN, token.Position{Offset: 16, Line: 2, Column: 7}},
+		{token.INT, token.Position{Offset: 18, Line: 2, Column: 9}},
+		{token.SLASH, token.Position{Offset: 21, Line: 2, Column: 12}},
+		{token.INT, token.Position{Offset: 23, Line: 2, Column: 14}},
+		{token.SEMICOLON, token.Position{Offset: 24, Line: 2, Column: 15}},
+		{token.STRING, token.Position{Offset: 36, Line: 3, Column: 3}},
+		{token.IDENT, token.Position{Offset: 42, Line: 4, Column: 4}},
+		{token.EOF, token.Position{Offset: 43, Line: 4, Column: 5}},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - wrong token type, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - wrong position for %q, expected=%+v, got=%+v", i, tok.Literal, tt.expectedPos, tok.Pos)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != 2 {
+		t.Fatalf("wrong number of comments, got=%d", len(comments))
+	}
+	if comments[0].Literal != "// header" || comments[0].Pos.Line != 1 {
+		t.Errorf("wrong first comment, got=%+v", comments[0])
+	}
+	if comments[1].Literal != "// half" || comments[1].Pos != (token.Position{Offset: 26, Line: 2, Column: 17}) {
+		t.Errorf("wrong second comment, got=%+v", comments[1])
+	}
+}
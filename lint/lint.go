@@ -0,0 +1,149 @@
+// Package lint finds suspicious code in Morty programs without running them.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"morty/ast"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/scope"
+	"os"
+	"sort"
+)
+
+// Rule is one check. Rules are enabled or disabled by name in a Config.
+type Rule struct {
+	Name string
+	Code diagnostic.Code
+	Doc  string
+	run  func(*pass)
+}
+
+// Rules lists every rule, in the order they run.
+var Rules = []*Rule{
+	{"undefined", diagnostic.UndefinedName, "names that are declared nowhere", checkUndefined},
+	{"unused", diagnostic.UnusedBinding, "let bindings and parameters that are never used, unless named _ or _name", checkUnused},
+	{"shadow", diagnostic.ShadowedBinding, "bindings hiding a binding of an enclosing function or a builtin", checkShadow},
+	{"unreachable", diagnostic.UnreachableCode, "statements after a return or throw in the same block", checkUnreachable},
+	{"arity", diagnostic.WrongArgCount, "calls to known functions and builtins with the wrong number of arguments", checkArity},
+	{"constant-condition", diagnostic.ConstantCondition, "if conditions made only of literals", checkConstantCondition},
+	{"type-mismatch", diagnostic.LiteralMismatch, "operators on literals whose types never work together", checkTypeMismatch},
+}
+
+// Rule levels of a Config
+const (
+	OFF     = "off"
+	WARNING = "warning"
+	ERROR   = "error"
+)
+
+// ConfigFile is where morty lint and the language server look for a Config,
+// in the current directory or the workspace.
+const ConfigFile = ".mortylint.json"
+
+// Config sets the level of each rule by name. Rules it does not list are warnings.
+type Config struct {
+	Rules map[string]string `json:"rules"`
+}
+
+// LoadConfig reads a Config from a JSON file such as
+//
+//	{"rules": {"shadow": "off", "arity": "error"}}
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %s", path, err)
+	}
+	return c, c.Validate()
+}
+
+// Validate reports rules and levels that do not exist.
+func (c Config) Validate() error {
+	for name, level := range c.Rules {
+		if rule(name) == nil {
+			return fmt.Errorf("unknown lint rule %q", name)
+		}
+		switch level {
+		case OFF, WARNING, ERROR:
+		default:
+			return fmt.Errorf("rule %s: unknown level %q, want %s, %s or %s", name, level, OFF, WARNING, ERROR)
+		}
+	}
+	return nil
+}
+
+// Set sets the level of the named rules.
+func (c *Config) Set(level string, names ...string) {
+	if c.Rules == nil {
+		c.Rules = map[string]string{}
+	}
+	for _, name := range names {
+		c.Rules[name] = level
+	}
+}
+
+func rule(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+var builtins = evaluator.NewRegistry()
+
+// Lint runs the rules enabled by c on program, returning their findings in source order.
+func Lint(program *ast.Program, c Config) []diagnostic.Diagnostic {
+	info := scope.Resolve(program, builtins.Names())
+
+	var diags []diagnostic.Diagnostic
+	for _, r := range Rules {
+		severity := diagnostic.Warning
+		switch c.Rules[r.Name] {
+		case OFF:
+			continue
+		case ERROR:
+			severity = diagnostic.Error
+		}
+		r.run(&pass{program: program, info: info, rule: r, severity: severity, diags: &diags})
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Primary.Span.Start.Offset < diags[j].Primary.Span.Start.Offset
+	})
+	return diags
+}
+
+type pass struct {
+	program  *ast.Program
+	info     *scope.Info
+	rule     *Rule
+	severity diagnostic.Severity
+	diags    *[]diagnostic.Diagnostic
+}
+
+func (p *pass) report(span diagnostic.Span, format string, a ...interface{}) *diagnostic.Diagnostic {
+	d := diagnostic.Errorf(p.rule.Code, span, format, a...)
+	d.Severity = p.severity
+	d.Notes = append(d.Notes, fmt.Sprintf("reported by the %s rule", p.rule.Name))
+	*p.diags = append(*p.diags, d)
+	return &(*p.diags)[len(*p.diags)-1]
+}
+
+// scopes calls fn for every scope of the program, outermost first.
+func (p *pass) scopes(fn func(*scope.Scope)) {
+	var visit func(*scope.Scope)
+	visit = func(s *scope.Scope) {
+		fn(s)
+		for _, child := range s.Children {
+			visit(child)
+		}
+	}
+	visit(p.info.Root)
+}
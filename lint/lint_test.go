@@ -0,0 +1,145 @@
+package lint
+
+import (
+	"fmt"
+	"morty/diagnostic"
+	"morty/lexer"
+	"morty/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// findings lints input and returns its findings as "code line:column message".
+func findings(t *testing.T, input string, c Config) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	found := []string{}
+	for _, d := range Lint(program, c) {
+		start := d.Primary.Span.Start
+		found = append(found, fmt.Sprintf("%s %d:%d %s", d.Code, start.Line, start.Column, d.Message))
+	}
+	return found
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		input    string
+		expected []string
+	}{
+		{"undefined", "let a = 1; puts(a, b);", []string{"W0101 1:20 undefined: b"}},
+		{"undefined", "let f = fn() { g() }; let g = fn() { 1 }; f();", nil},
+		{"unused", "let a = 1; let _b = 2; let f = fn(x, _y) { 1 }; f(1, 2);", []string{
+			"W0102 1:5 a declared and not used",
+			"W0102 1:35 parameter x is not used",
+		}},
+		{"unused", "fn fact(n) { if (n < 1) { 1 } else { n * fact(n - 1) } }", []string{"W0102 1:4 fact declared and not used"}},
+		{"unused", "try { 1 } catch (e) { 2 }", nil},
+		{"unused", "enum E { A(x), B } enum F { C } match (C) { case C { 1 } }", []string{"W0102 1:6 E declared and not used"}},
+		{"unused", "struct Point { x } fn f(p: Point) { p.x } f(1)", nil},
+		{"unused", "struct Point { x } let p: Point = 1; fn f() -> Point { p } f()", nil},
+		{"unused", "trait Shape { area } fn total(a: Shape) { a.area() } total(1)", nil},
+		{"unused", "struct Sq { s } extend Sq { fn one(self, n) { 1 } } Sq{s: 1}.one(2)", []string{"W0102 1:42 parameter n is not used"}},
+		{"shadow", "let x = 1; let f = fn(x) { let len = 2; len + x }; f(x);", []string{
+			"W0103 1:23 x shadows the x declared in an enclosing scope",
+			"W0103 1:32 len shadows the builtin len",
+		}},
+		{"shadow", "let x = 1; let x = x + 1; x", nil},
+		{"unreachable", "fn f() { return 1; puts(2); puts(3); } f();", []string{"W0104 1:20 unreachable code"}},
+		{"unreachable", "fn f() { if (true) { throw \"x\"; 1 } else { return 2 } } f();", []string{"W0104 1:33 unreachable code"}},
+		{"unreachable", "fn f() { puts(1); return 2 } f();", nil},
+		{"arity", "let add = fn(a, b) { a + b }; add(1); add(1, 2); len(); puts(); len(\"a\", \"b\")", []string{
+			"W0105 1:31 wrong number of arguments to `add`. got=1, want=2",
+			"W0105 1:50 wrong number of arguments to `len`. got=0, want=1",
+			"W0105 1:65 wrong number of arguments to `len`. got=2, want=1",
+		}},
+		{"arity", "let f = fn(g) { g(1, 2) }; f(len);", nil},
+		{"constant-condition", "let x = 1; if (true) { 1 }; if (!(1 > 2)) { 2 }; if (x > 1) { 3 }", []string{
+			"W0106 1:16 if condition true is constant",
+			"W0106 1:33 if condition (!(1 > 2)) is constant",
+		}},
+		{"type-mismatch", `1 == "1"; 1 != true; "a" - 1; 1 < "b"; true + false; "a" * "b"; "a" + "b"; 1 * 2; [1] == [1]`, []string{
+			"W0107 1:1 comparison of INTEGER with STRING is always false",
+			"W0107 1:11 comparison of INTEGER with BOOLEAN is always true",
+			"W0107 1:22 type mismatch: STRING - INTEGER always fails",
+			"W0107 1:31 type mismatch: INTEGER < STRING always fails",
+			"W0107 1:40 unknown operator: BOOLEAN + BOOLEAN always fails",
+			"W0107 1:54 unknown operator: STRING * STRING always fails",
+		}},
+	}
+
+	for _, tt := range tests {
+		only := Config{}
+		for _, r := range Rules {
+			if r.Name != tt.rule {
+				only.Set(OFF, r.Name)
+			}
+		}
+
+		got := findings(t, tt.input, only)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: %q\nwant=%q\ngot= %q", tt.rule, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	input := "let a = 1; let len = 2;"
+
+	all := findings(t, input, Config{})
+	if len(all) != 3 {
+		t.Errorf("expected 3 findings with the default config, got=%q", all)
+	}
+
+	c := Config{}
+	c.Set(OFF, "shadow")
+	c.Set(ERROR, "unused")
+	p := parser.New(lexer.New(input))
+	diags := Lint(p.ParseProgram(), c)
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 findings, got=%d", len(diags))
+	}
+	for _, d := range diags {
+		if d.Code != diagnostic.UnusedBinding || d.Severity != diagnostic.Error {
+			t.Errorf("expected unused errors only, got=%s %s", d.Severity, d.Code)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{`{"rules": {"shadow": "off", "arity": "error"}}`, ""},
+		{`{"rules": {"shadows": "off"}}`, `unknown lint rule "shadows"`},
+		{`{"rules": {"shadow": "loud"}}`, `rule shadow: unknown level "loud", want off, warning or error`},
+		{`{"rules": [}`, "invalid character"},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", i))
+		os.WriteFile(path, []byte(tt.content), 0o644)
+
+		c, err := LoadConfig(path)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", tt.content, err)
+			} else if c.Rules["shadow"] != OFF || c.Rules["arity"] != ERROR {
+				t.Errorf("%s: wrong config %v", tt.content, c.Rules)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got=%v", tt.content, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package lint
+
+import (
+	"morty/ast"
+	"morty/diagnostic"
+	"morty/object"
+	"morty/scope"
+	"morty/token"
+	"strings"
+)
+
+func checkUndefined(p *pass) {
+	for _, ident := range p.info.Unresolved {
+		p.report(diagnostic.TokenSpan(ident.Token), "undefined: %s", ident.Value)
+	}
+}
+
+func checkUnused(p *pass) {
+	receivers := receivers(p.program)
+	p.scopes(func(s *scope.Scope) {
+		for _, b := range s.Bindings {
+			if used(b) || b.Kind == scope.Enum && variantUsed(s, b) || receivers[b.Ident] || strings.HasPrefix(b.Name, "_") {
+				continue
+			}
+			switch b.Kind {
+			case scope.Let, scope.Function, scope.Struct, scope.Trait, scope.Enum:
+				p.report(diagnostic.TokenSpan(b.Ident.Token), "%s declared and not used", b.Name)
+			case scope.Param:
+				d := p.report(diagnostic.TokenSpan(b.Ident.Token), "parameter %s is not used", b.Name)
+				d.Suggestions = append(d.Suggestions, diagnostic.Suggestion{
+					Message:     "rename it to _" + b.Name + " if it is unused on purpose",
+					Span:        diagnostic.TokenSpan(b.Ident.Token),
+					Replacement: "_" + b.Name,
+				})
+			}
+		}
+	})
+}
+
+// used reports whether b is used, not counting the recursive calls of a function.
+func used(b *scope.Binding) bool {
+	fn := b.Function()
+	if fn == nil {
+		return len(b.Uses) > 0
+	}
+	body := diagnostic.NodeSpan(fn)
+	for _, use := range b.Uses {
+		if at := use.Token.Pos.Offset; at < body.Start.Offset || at >= body.End.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// receivers returns the first parameters of the methods of the extends in
+// program, which a method has whether it uses its receiver or not.
+func receivers(program *ast.Program) map[*ast.Identifier]bool {
+	found := map[*ast.Identifier]bool{}
+	ast.Inspect(program, func(n ast.Noder) bool {
+		if extend, ok := n.(*ast.ExtendStatement); ok {
+			for _, method := range extend.Methods {
+				if len(method.Parameters) > 0 {
+					found[method.Parameters[0]] = true
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// variantUsed reports whether a variant of the enum b is used.
+func variantUsed(s *scope.Scope, b *scope.Binding) bool {
+	for _, v := range s.Bindings {
+		if v.Kind == scope.Variant && v.Node == b.Node && len(v.Uses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func checkShadow(p *pass) {
+	p.scopes(func(s *scope.Scope) {
+		for _, b := range s.Bindings {
+			if b.Kind == scope.Builtin || strings.HasPrefix(b.Name, "_") {
+				continue
+			}
+			for outer := s.Parent; outer != nil; outer = outer.Parent {
+				hidden := outer.Lookup(b.Name)
+				if len(hidden) == 0 {
+					continue
+				}
+				span := diagnostic.TokenSpan(b.Ident.Token)
+				if hidden[0].Kind == scope.Builtin {
+					p.report(span, "%s shadows the builtin %s", b.Name, b.Name)
+				} else {
+					d := p.report(span, "%s shadows the %s declared in an enclosing scope", b.Name, b.Name)
+					d.Secondary = append(d.Secondary, diagnostic.Label{
+						Span:    diagnostic.TokenSpan(hidden[0].Ident.Token),
+						Message: "shadowed " + b.Name + " declared here",
+					})
+				}
+				break
+			}
+		}
+	})
+}
+
+func checkUnreachable(p *pass) {
+	ast.Inspect(p.program, func(n ast.Noder) bool {
+		block, ok := n.(*ast.BlockStatement)
+		if !ok {
+			return true
+		}
+		for i, stmt := range block.Statements[:max(len(block.Statements)-1, 0)] {
+			var exit token.Token
+			switch stmt := stmt.(type) {
+			case *ast.ReturnStatement:
+				exit = stmt.Token
+			case *ast.ThrowStatement:
+				exit = stmt.Token
+			default:
+				continue
+			}
+			d := p.report(diagnostic.NodeSpan(block.Statements[i+1]), "unreachable code")
+			d.Secondary = append(d.Secondary, diagnostic.Label{Span: diagnostic.TokenSpan(exit), Message: "after this " + exit.Literal})
+			break
+		}
+		return true
+	})
+}
+
+func checkArity(p *pass) {
+	ast.Inspect(p.program, func(n ast.Noder) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		b, ok := p.info.Uses[ident]
+		if !ok {
+			return true
+		}
+
+		want := -1
+		if fn := b.Function(); fn != nil {
+			want = len(fn.Parameters)
+		} else if builtin, ok := builtins.Lookup(b.Name); ok && b.Kind == scope.Builtin && builtin.Arity != object.VARIADIC {
+			want = builtin.Arity
+		}
+		if want >= 0 && len(call.Arguments) != want {
+			p.report(diagnostic.NodeSpan(call), "wrong number of arguments to `%s`. got=%d, want=%d", b.Name, len(call.Arguments), want)
+		}
+		return true
+	})
+}
+
+func checkConstantCondition(p *pass) {
+	ast.Inspect(p.program, func(n ast.Noder) bool {
+		if ife, ok := n.(*ast.IfExpression); ok && constant(ife.Condition) {
+			p.report(diagnostic.NodeSpan(ife.Condition), "if condition %s is constant", ife.Condition.ToString())
+		}
+		return true
+	})
+}
+
+// constant reports whether exp only combines literals, so that it always has the same value.
+func constant(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		return constant(exp.Right)
+	case *ast.InfixExpression:
+		return constant(exp.Left) && constant(exp.Right)
+	default:
+		return literalType(exp) != ""
+	}
+}
+
+// literalType returns the type of the object a literal evaluates to, or "" if exp is not a literal.
+func literalType(exp ast.Expression) object.ObjectType {
+	switch exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+	}
+	return ""
+}
+
+// checkTypeMismatch mirrors evalInfixExpression: operators between literals
+// of types it does not combine always fail, and == or != between different
+// types always compare identities that differ.
+func checkTypeMismatch(p *pass) {
+	ast.Inspect(p.program, func(n ast.Noder) bool {
+		infix, ok := n.(*ast.InfixExpression)
+		if !ok {
+			return true
+		}
+		left, right := literalType(infix.Left), literalType(infix.Right)
+		if left == "" || right == "" {
+			return true
+		}
+
+		span := diagnostic.NodeSpan(infix)
+		switch {
+		case left != right && infix.Operator == "==":
+			p.report(span, "comparison of %s with %s is always false", left, right)
+		case left != right && infix.Operator == "!=":
+			p.report(span, "comparison of %s with %s is always true", left, right)
+		case left != right:
+			p.report(span, "type mismatch: %s %s %s always fails", left, infix.Operator, right)
+		case left == object.INTEGER_OBJ, infix.Operator == "==", infix.Operator == "!=":
+		case left == object.STRING_OBJ && infix.Operator == "+":
+		default:
+			p.report(span, "unknown operator: %s %s %s always fails", left, infix.Operator, right)
+		}
+		return true
+	})
+}
@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"morty/ast"
+	"morty/diagnostic"
+	"morty/lexer"
+	"morty/lint"
+	"morty/parser"
+	"morty/scope"
+	"sort"
+	"unicode/utf8"
+)
+
+// document is an open file, analysed again on every change.
+type document struct {
+	uri     string
+	text    string
+	lines   []int // offsets at which lines start
+	program *ast.Program
+	diags   []diagnostic.Diagnostic
+	info    *scope.Info
+}
+
+// newDocument parses text and lints it with config, as morty lint would:
+// not at all if it does not parse, since half-typed code is all fallout.
+func newDocument(uri, text string, builtins []string, config lint.Config) *document {
+	d := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.diags = p.Diagnostics()
+	d.info = scope.Resolve(d.program, builtins)
+
+	if len(d.diags) == 0 {
+		d.diags = lint.Lint(d.program, config)
+	}
+	return d
+}
+
+// offset converts an LSP position to a byte offset in the text.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// position converts a byte offset in the text to an LSP position.
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.text))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+
+	units := 0
+	for _, r := range d.text[d.lines[line]:offset] {
+		units += utf16Len(r)
+	}
+	return Position{Line: line, Character: units}
+}
+
+func (d *document) rangeOf(span diagnostic.Span) Range {
+	return Range{Start: d.position(span.Start.Offset), End: d.position(span.End.Offset)}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
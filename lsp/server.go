@@ -0,0 +1,379 @@
+// Package lsp implements a language server for Morty, speaking JSON-RPC over
+// a pair of streams such as standard input and output.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"morty/ast"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/lint"
+	"morty/scope"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Server answers the requests of one editor session.
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	builtins *evaluator.Registry
+	lint     lint.Config // from the lint.ConfigFile of the workspace, if any
+	docs     map[string]*document
+	shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		builtins: evaluator.NewRegistry(),
+		docs:     map[string]*document{},
+	}
+}
+
+// errNoShutdown is returned by Serve when the client exits without asking
+// the server to shut down first.
+var errNoShutdown = errors.New("exit without shutdown")
+
+// Serve handles messages until the client sends exit or closes the input.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: parseError, Message: err.Error()})
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errNoShutdown
+			}
+			return nil
+		}
+
+		result, err := s.handle(req)
+		if req.ID == nil {
+			continue // a notification, which gets no reply
+		}
+		var rpcErr *rpcError
+		if err != nil && !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: invalidParams, Message: err.Error()}
+		}
+		s.reply(req.ID, result, rpcErr)
+	}
+}
+
+func (s *Server) handle(req request) (interface{}, error) {
+	if s.shutdown {
+		return nil, &rpcError{Code: invalidRequest, Message: "server is shut down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, err
+			}
+		}
+		s.loadLintConfig(params.RootURI)
+
+		var result initializeResult
+		result.Capabilities = serverCapabilities{
+			TextDocumentSync:       syncFull,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     map[string]interface{}{},
+		}
+		result.ServerInfo.Name = "morty"
+		return result, nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.open(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.open(params.TextDocument.URI, params.ContentChanges[n-1].Text) // full sync
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+
+	case "textDocument/hover":
+		return s.withPosition(req.Params, s.hover)
+
+	case "textDocument/definition":
+		return s.withPosition(req.Params, s.definition)
+
+	case "textDocument/completion":
+		return s.withPosition(req.Params, s.completion)
+
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.symbols(doc, doc.info.Root), nil
+	}
+
+	return nil, &rpcError{Code: methodNotFound, Message: "method not supported: " + req.Method}
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: invalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+func (s *Server) withPosition(raw json.RawMessage, fn func(*document, int) interface{}) (interface{}, error) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return fn(doc, doc.offset(params.Position)), nil
+}
+
+// loadLintConfig reads the lint.ConfigFile at the root of the workspace,
+// telling the user if it is invalid, in which case the defaults are used.
+func (s *Server) loadLintConfig(rootURI string) {
+	root, err := url.Parse(rootURI)
+	if rootURI == "" || err != nil || root.Scheme != "file" {
+		return
+	}
+	config, err := lint.LoadConfig(filepath.Join(filepath.FromSlash(root.Path), lint.ConfigFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		s.notify("window/showMessage", showMessageParams{Type: 1, Message: "morty: " + err.Error()})
+		return
+	}
+	s.lint = config
+}
+
+func (s *Server) open(uri, text string) {
+	doc := newDocument(uri, text, s.builtins.Names(), s.lint)
+	s.docs[uri] = doc
+
+	diags := []Diagnostic{}
+	for _, d := range doc.diags {
+		diag := Diagnostic{
+			Range:    doc.rangeOf(d.Primary.Span),
+			Severity: int(d.Severity) + 1, // 1 is error, 2 warning and 3 information
+			Code:     string(d.Code),
+			Source:   "morty",
+			Message:  d.Message,
+		}
+		for _, l := range d.Secondary {
+			diag.RelatedInformation = append(diag.RelatedInformation, diagnosticRelatedInformation{
+				Location: Location{URI: uri, Range: doc.rangeOf(l.Span)},
+				Message:  l.Message,
+			})
+		}
+		diags = append(diags, diag)
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *Server) hover(doc *document, offset int) interface{} {
+	ident, b := doc.info.IdentAt(offset)
+	if b == nil {
+		return nil
+	}
+
+	text := "```morty\n" + s.signature(b) + "\n```"
+	if b.Kind == scope.Builtin {
+		if builtin, ok := s.builtins.Lookup(b.Name); ok && builtin.Doc != "" {
+			text += "\n\n" + builtin.Doc
+		}
+	}
+	return Hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    doc.rangeOf(diagnostic.TokenSpan(ident.Token)),
+	}
+}
+
+// signature describes b the way it was declared, as in "let add = fn(a, b)".
+func (s *Server) signature(b *scope.Binding) string {
+	switch b.Kind {
+	case scope.Builtin:
+		if builtin, ok := s.builtins.Lookup(b.Name); ok {
+			if strings.HasPrefix(builtin.Doc, b.Name+"(") {
+				return "builtin " + builtin.Doc[:strings.Index(builtin.Doc, ")")+1]
+			}
+		}
+		return "builtin " + b.Name
+	case scope.Function:
+		return "fn " + b.Name + "(" + params(b.Function()) + ")"
+	}
+
+	if fn := b.Function(); fn != nil {
+		return fmt.Sprintf("%s %s = fn(%s)", b.Kind, b.Name, params(fn))
+	}
+	return b.Kind.String() + " " + b.Name
+}
+
+func params(fn *ast.FunctionLiteral) string {
+	names := []string{}
+	for _, p := range fn.Parameters {
+		names = append(names, p.Value)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *Server) definition(doc *document, offset int) interface{} {
+	_, b := doc.info.IdentAt(offset)
+	if b == nil || b.Ident == nil {
+		return nil
+	}
+	return Location{URI: doc.uri, Range: doc.rangeOf(diagnostic.TokenSpan(b.Ident.Token))}
+}
+
+func (s *Server) completion(doc *document, offset int) interface{} {
+	items := []CompletionItem{}
+	for _, b := range doc.info.ScopeAt(offset).Visible(offset) {
+		item := CompletionItem{Label: b.Name, Kind: completionVariable, Detail: s.signature(b)}
+		if b.Kind == scope.Builtin || b.Function() != nil {
+			item.Kind = completionFunction
+		}
+		if builtin, ok := s.builtins.Lookup(b.Name); ok && b.Kind == scope.Builtin {
+			item.Documentation = builtin.Doc
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// symbols lists the lets, named functions, structs, traits and enums of sc, with those of the functions they bind nested.
+func (s *Server) symbols(doc *document, sc *scope.Scope) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, b := range sc.Bindings {
+		switch b.Kind {
+		case scope.Let, scope.Function, scope.Struct, scope.Trait, scope.Enum:
+		default:
+			continue
+		}
+		sym := DocumentSymbol{
+			Name:           b.Name,
+			Kind:           symbolVariable,
+			Range:          doc.rangeOf(diagnostic.NodeSpan(b.Node)),
+			SelectionRange: doc.rangeOf(diagnostic.TokenSpan(b.Ident.Token)),
+		}
+		switch decl := b.Node.(type) {
+		case *ast.StructStatement:
+			sym.Kind = symbolStruct
+			sym.Detail = decl.ToString()
+		case *ast.TraitStatement:
+			sym.Kind = symbolInterface
+			sym.Detail = decl.ToString()
+		case *ast.EnumStatement:
+			sym.Kind = symbolEnum
+			sym.Detail = decl.ToString()
+		}
+		if fn := b.Function(); fn != nil {
+			sym.Kind = symbolFunction
+			sym.Detail = "fn(" + params(fn) + ")"
+			for _, child := range sc.Children {
+				if child.Node == ast.Noder(fn) {
+					sym.Children = s.symbols(doc, child)
+				}
+			}
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols
+}
+
+// read returns the body of the next message, framed by a Content-Length header.
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (s *Server) write(msg interface{}) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		panic(err) // the messages are plain structs
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, err *rpcError) {
+	resp := response{JSONRPC: "2.0", ID: id, Error: err}
+	if id == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	if err == nil {
+		resp.Result, _ = json.Marshal(result)
+	}
+	s.write(resp)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
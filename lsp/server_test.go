@@ -0,0 +1,350 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"morty/lint"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///main.morty"
+
+// session frames msgs as a client would, serves them and returns the messages
+// the server sent back, which must all be valid frames.
+func session(t *testing.T, msgs ...string) []map[string]interface{} {
+	t.Helper()
+
+	var in bytes.Buffer
+	for _, msg := range msgs {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Serve(); err != nil {
+		t.Fatalf("Serve: %s", err)
+	}
+
+	replies := []map[string]interface{}{}
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatalf("bad header: %s", err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatalf("short body: %s", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("bad JSON %q: %s", body, err)
+		}
+		replies = append(replies, msg)
+	}
+}
+
+func open(text string) string {
+	params, _ := json.Marshal(map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "morty", "version": 1, "text": text},
+	})
+	return `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":` + string(params) + `}`
+}
+
+func at(id int, method string, line, character int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d}}}`,
+		id, method, uri, line, character)
+}
+
+// reply returns the reply to the request with id.
+func reply(t *testing.T, msgs []map[string]interface{}, id int) map[string]interface{} {
+	t.Helper()
+	for _, msg := range msgs {
+		if msg["id"] == float64(id) {
+			return msg
+		}
+	}
+	t.Fatalf("no reply to request %d in %v", id, msgs)
+	return nil
+}
+
+// toJSON renders v compactly, to compare results with expected JSON.
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestLifecycle(t *testing.T) {
+	msgs := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"workspace/symbol","params":{}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/hover","params":{}}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 replies, got=%d: %v", len(msgs), msgs)
+	}
+
+	caps := toJSON(reply(t, msgs, 1)["result"].(map[string]interface{})["capabilities"])
+	expected := `{"completionProvider":{},"definitionProvider":true,"documentSymbolProvider":true,"hoverProvider":true,"textDocumentSync":1}`
+	if caps != expected {
+		t.Errorf("wrong capabilities. want=%s, got=%s", expected, caps)
+	}
+
+	if code := reply(t, msgs, 2)["error"].(map[string]interface{})["code"]; code != float64(methodNotFound) {
+		t.Errorf("expected method not found, got=%v", code)
+	}
+	if shutdown := reply(t, msgs, 3); toJSON(shutdown) != `{"id":3,"jsonrpc":"2.0","result":null}` {
+		t.Errorf("wrong reply to shutdown %s", toJSON(shutdown))
+	}
+	if code := reply(t, msgs, 4)["error"].(map[string]interface{})["code"]; code != float64(invalidRequest) {
+		t.Errorf("expected invalid request after shutdown, got=%v", code)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	in := strings.NewReader("Content-Length: 33\r\n\r\n" + `{"jsonrpc":"2.0","method":"exit"}`)
+	if err := NewServer(in, io.Discard).Serve(); err != errNoShutdown {
+		t.Errorf("expected errNoShutdown, got=%v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	msgs := session(t,
+		open("let x = 5;\nlet = 1;\nputs(y);\nputs(x);"),
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"`+uri+`","version":2},"contentChanges":[{"text":"puts(5);"}]}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":"`+uri+`"}}}`,
+	)
+
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 notifications, got=%d: %v", len(msgs), msgs)
+	}
+	for _, msg := range msgs {
+		if msg["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("expected diagnostics, got=%v", msg)
+		}
+	}
+
+	// code that does not parse is not linted, as by morty lint
+	diags := toJSON(msgs[0]["params"].(map[string]interface{})["diagnostics"])
+	expected := `[` +
+		`{"code":"E0102","message":"expected next token to be IDENT, got = instead","range":{"end":{"character":5,"line":1},"start":{"character":4,"line":1}},"severity":1,"source":"morty"}` +
+		`]`
+	if diags != expected {
+		t.Errorf("wrong diagnostics.\nwant=%s\ngot= %s", expected, diags)
+	}
+
+	for _, msg := range msgs[1:] {
+		if diags := toJSON(msg["params"].(map[string]interface{})["diagnostics"]); diags != "[]" {
+			t.Errorf("expected no diagnostics, got=%s", diags)
+		}
+	}
+}
+
+func TestRelatedInformation(t *testing.T) {
+	msgs := session(t, open("let f = fn() {\n  1"))
+	diags := msgs[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	related := toJSON(diags[0].(map[string]interface{})["relatedInformation"])
+	expected := `[{"location":{"range":{"end":{"character":14,"line":0},"start":{"character":13,"line":0}},"uri":"file:///main.morty"},"message":"unclosed { opened here"}]`
+	if related != expected {
+		t.Errorf("wrong related information.\nwant=%s\ngot= %s", expected, related)
+	}
+}
+
+func TestHover(t *testing.T) {
+	text := "let add = fn(a, b) { a + b };\nfn twice(f) { f(f(1)) }\nlet n = add(1, 2);\nlen(\"é\"); n"
+	msgs := session(t,
+		open(text),
+		at(1, "textDocument/hover", 2, 9),
+		at(2, "textDocument/hover", 0, 21),
+		at(3, "textDocument/hover", 1, 4),
+		at(4, "textDocument/hover", 3, 1),
+		at(5, "textDocument/hover", 3, 11),
+		at(6, "textDocument/hover", 2, 16),
+	)
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		{1, "```morty\nlet add = fn(a, b)\n```"},
+		{2, "```morty\nparam a\n```"},
+		{3, "```morty\nfn twice(f)\n```"},
+		{4, "```morty\nbuiltin len(x)\n```\n\nlen(x) returns the number of bytes in the string x or the number of elements in the array x."},
+		{5, "```morty\nlet n\n```"}, // after a two byte, one unit character
+	}
+	for _, tt := range tests {
+		result, ok := reply(t, msgs, tt.id)["result"].(map[string]interface{})
+		if !ok {
+			t.Errorf("request %d: no hover", tt.id)
+			continue
+		}
+		if value := result["contents"].(map[string]interface{})["value"]; value != tt.expected {
+			t.Errorf("request %d: wrong hover. want=%q, got=%q", tt.id, tt.expected, value)
+		}
+	}
+
+	if result := reply(t, msgs, 6)["result"]; result != nil {
+		t.Errorf("expected no hover on a literal, got=%v", result)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	text := "let x = 1;\nlet f = fn(x) {\n  x + 1\n};\nf(x)"
+	msgs := session(t,
+		open(text),
+		at(1, "textDocument/definition", 2, 2),
+		at(2, "textDocument/definition", 4, 2),
+		at(3, "textDocument/definition", 4, 0),
+		at(4, "textDocument/definition", 2, 6),
+	)
+
+	expected := map[int]string{
+		1: `{"range":{"end":{"character":12,"line":1},"start":{"character":11,"line":1}},"uri":"file:///main.morty"}`,
+		2: `{"range":{"end":{"character":5,"line":0},"start":{"character":4,"line":0}},"uri":"file:///main.morty"}`,
+		3: `{"range":{"end":{"character":5,"line":1},"start":{"character":4,"line":1}},"uri":"file:///main.morty"}`,
+		4: `null`,
+	}
+	for id, want := range expected {
+		if got := toJSON(reply(t, msgs, id)["result"]); got != want {
+			t.Errorf("request %d: wrong definition.\nwant=%s\ngot= %s", id, want, got)
+		}
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	dir := t.TempDir()
+	initialize := func(id int) string {
+		params, _ := json.Marshal(map[string]interface{}{"rootUri": "file://" + filepath.ToSlash(dir), "capabilities": map[string]interface{}{}})
+		return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"initialize","params":%s}`, id, params)
+	}
+	text := "let x = 1;\nputs(y);"
+
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{"", `[{"code":"W0102","severity":2},{"code":"W0101","severity":2}]`},
+		{`{"rules": {"undefined": "error", "unused": "off"}}`, `[{"code":"W0101","severity":1}]`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, lint.ConfigFile)
+		os.Remove(path)
+		if tt.config != "" {
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		msgs := session(t, initialize(1), open(text))
+		type diagnostic struct {
+			Code     string `json:"code"`
+			Severity int    `json:"severity"`
+		}
+		var diags []diagnostic
+		raw, _ := json.Marshal(msgs[len(msgs)-1]["params"].(map[string]interface{})["diagnostics"])
+		json.Unmarshal(raw, &diags)
+		if got := toJSON(diags); got != tt.expected {
+			t.Errorf("wrong diagnostics with config %q.\nwant=%s\ngot= %s", tt.config, tt.expected, got)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, lint.ConfigFile), []byte(`{"rules": {"nope": "off"}}`), 0o644)
+	msgs := session(t, initialize(1))
+	if msgs[0]["method"] != "window/showMessage" {
+		t.Errorf("expected the invalid config to be shown, got=%v", msgs[0])
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	text := "let limit = 10;\nlet f = fn(a) {\n  let inner = a;\n  inner\n};\nfn g() { 1 }\nstruct P { x, y }\ntrait T { f }\nenum E { A(v), B }"
+	msgs := session(t,
+		open(text),
+		`{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"`+uri+`"}}}`,
+	)
+
+	type symbol struct {
+		Name     string
+		Detail   string
+		Kind     int
+		Children []symbol
+	}
+	var symbols []symbol
+	raw, _ := json.Marshal(reply(t, msgs, 1)["result"])
+	json.Unmarshal(raw, &symbols)
+
+	got := toJSON(symbols)
+	expected := `[{"Name":"limit","Detail":"","Kind":13,"Children":null},` +
+		`{"Name":"f","Detail":"fn(a)","Kind":12,"Children":[{"Name":"inner","Detail":"","Kind":13,"Children":null}]},` +
+		`{"Name":"g","Detail":"fn()","Kind":12,"Children":null},` +
+		`{"Name":"P","Detail":"struct P { x, y }","Kind":23,"Children":null},` +
+		`{"Name":"T","Detail":"trait T { f }","Kind":11,"Children":null},` +
+		`{"Name":"E","Detail":"enum E { A(v), B }","Kind":10,"Children":null}]`
+	if got != expected {
+		t.Errorf("wrong symbols.\nwant=%s\ngot= %s", expected, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	text := "let total = 1;\nlet f = fn(arg) {\n  let local = arg;\n  \n};\nlet later = 2;"
+	msgs := session(t,
+		open(text),
+		at(1, "textDocument/completion", 3, 2),
+		at(2, "textDocument/completion", 0, 0),
+	)
+
+	labels := func(id int) map[string]float64 {
+		found := map[string]float64{}
+		for _, item := range reply(t, msgs, id)["result"].([]interface{}) {
+			item := item.(map[string]interface{})
+			found[item["label"].(string)] = item["kind"].(float64)
+		}
+		return found
+	}
+
+	inside := labels(1)
+	for name, kind := range map[string]float64{"arg": completionVariable, "local": completionVariable, "total": completionVariable, "f": completionFunction, "len": completionFunction, "puts": completionFunction} {
+		if inside[name] != kind {
+			t.Errorf("inside f: expected %s of kind %v, got=%v", name, kind, inside[name])
+		}
+	}
+
+	top := labels(2)
+	for _, name := range []string{"arg", "local", "total"} {
+		if _, ok := top[name]; ok {
+			t.Errorf("at the start: %s should not be offered", name)
+		}
+	}
+	if _, ok := top["len"]; !ok {
+		t.Errorf("at the start: builtins should be offered")
+	}
+}
+
+// Editors send every keystroke, so documents are mostly broken. Analysing
+// them must not panic, whatever was cut out.
+func TestPartialDocuments(t *testing.T) {
+	src := "let f = fn(a, b) { let c = a + b; if (c > 1) { return try { c } catch (e) { e.message } finally { 1 } } else { [1, quote(unquote(a))] } }; f(1)?; macro(x) { x }; a.b = 3; throw \"x\";"
+	for i := 0; i <= len(src); i++ {
+		for j := i; j <= len(src); j += 7 {
+			d := newDocument("u", src[:i]+src[j:], []string{"len"}, lint.Config{})
+			for off := 0; off <= len(d.text); off++ {
+				d.info.IdentAt(off)
+				d.info.ScopeAt(off).Visible(off)
+				d.position(off)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package object
+
+import (
+	"sync/atomic"
+)
+
+// Task is a function running on its own goroutine.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+func NewTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return "task" }
+
+// Finish records the result of the task and wakes up everyone waiting on it.
+func (t *Task) Finish(result Object) {
+	t.result = result
+	close(t.done)
+}
+
+// Done is closed once the task has finished.
+func (t *Task) Done() <-chan struct{} { return t.done }
+
+// Result returns the result of a finished task.
+func (t *Task) Result() Object { return t.result }
+
+type Channel struct {
+	C      chan Object
+	closed atomic.Bool
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{C: make(chan Object, capacity)}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return "channel" }
+
+// Close closes the channel, reporting false if it already was.
+func (c *Channel) Close() bool {
+	if !c.closed.CompareAndSwap(false, true) {
+		return false
+	}
+	close(c.C)
+	return true
+}
+
+func (c *Channel) Closed() bool { return c.closed.Load() }
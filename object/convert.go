@@ -0,0 +1,188 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// FromGo converts a Go value into its Morty object. Objects are passed through unchanged.
+func FromGo(v interface{}) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+	if obj, ok := v.(Object); ok {
+		return obj, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("Go value %d of type %s overflows a morty integer", rv.Uint(), rv.Type())
+		}
+		return &Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.String:
+		return &String{Value: rv.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return NULL, nil
+		}
+		return FromGo(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return NULL, nil
+		}
+		elements := make([]Object, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			el, err := FromGo(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, el)
+		}
+		return &Array{Elements: elements}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert Go value of type %s to a morty object", rv.Type())
+	}
+}
+
+// ToGo converts obj into the closest Go value: int64, bool, string, nil,
+// []interface{}, map[string]interface{} for structs or the value wrapped by a Native.
+// Objects without a Go counterpart (functions, builtins) are returned as is,
+// and a struct reached again from its own fields as its Inspect string.
+func ToGo(obj Object) interface{} {
+	return toGo(obj, map[*Struct]bool{})
+}
+
+// toGo converts obj, within the structs in converting.
+func toGo(obj Object, converting map[*Struct]bool) interface{} {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil
+	case *Integer:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *String:
+		return obj.Value
+	case *Native:
+		return obj.Value.Interface()
+	case *Array:
+		elements := make([]interface{}, 0, len(obj.Elements))
+		for _, el := range obj.Elements {
+			elements = append(elements, toGo(el, converting))
+		}
+		return elements
+	case *Struct:
+		if converting[obj] {
+			return obj.Inspect()
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+
+		values := obj.Fields()
+		fields := make(map[string]interface{}, len(values))
+		for i, field := range obj.Def.Fields {
+			fields[field] = toGo(values[i], converting)
+		}
+		return fields
+	default:
+		return obj
+	}
+}
+
+// ConvertTo converts obj into a Go value of type t.
+func ConvertTo(obj Object, t reflect.Type) (reflect.Value, error) {
+	if obj != nil && reflect.TypeOf(obj) == t {
+		return reflect.ValueOf(obj), nil
+	}
+	if native, ok := obj.(*Native); ok && native.Value.Type().AssignableTo(t) {
+		v := reflect.New(t).Elem()
+		v.Set(native.Value)
+		return v, nil
+	}
+	if t.Kind() == reflect.Interface && t != emptyInterfaceType {
+		if obj == nil || !reflect.TypeOf(obj).Implements(t) {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		v := reflect.New(t).Elem()
+		v.Set(reflect.ValueOf(obj))
+		return v, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		v := reflect.New(t).Elem()
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+		return v, nil
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+	case reflect.Slice:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return reflect.Value{}, conversionError(obj, t)
+		}
+		v := reflect.MakeSlice(t, 0, len(arr.Elements))
+		for idx, el := range arr.Elements {
+			goEl, err := ConvertTo(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", idx, err)
+			}
+			v = reflect.Append(v, goEl)
+		}
+		return v, nil
+	case reflect.Interface:
+		v := reflect.New(t).Elem()
+		if goVal := ToGo(obj); goVal != nil {
+			v.Set(reflect.ValueOf(goVal))
+		}
+		return v, nil
+	default:
+		return reflect.Value{}, conversionError(obj, t)
+	}
+}
+
+var emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+func conversionError(obj Object, t reflect.Type) error {
+	if obj == nil {
+		return fmt.Errorf("cannot convert nil to %s", t)
+	}
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
@@ -0,0 +1,98 @@
+package object
+
+import "strings"
+
+// EnumType is an enum declaration, such as enum Shape { Circle(r), Empty }.
+type EnumType struct {
+	Name     string
+	Variants []*Variant
+}
+
+func (et *EnumType) Type() ObjectType { return ENUM_OBJ }
+func (et *EnumType) Inspect() string {
+	variants := []string{}
+	for _, v := range et.Variants {
+		variants = append(variants, v.declaration())
+	}
+	return "enum " + et.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// GetMember returns what the variant so named stands for, as in Shape.Circle(1).
+func (et *EnumType) GetMember(name string) (Object, bool) {
+	for _, v := range et.Variants {
+		if v.Name == name {
+			return v.Binding(), true
+		}
+	}
+	return nil, false
+}
+
+// Variant is a variant of an enum. Called with a value for each of its
+// fields, it makes an EnumValue.
+type Variant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+	unit   *EnumValue // the only value of a variant without fields
+}
+
+// NewVariant adds the variant name with fields to enum.
+func NewVariant(enum *EnumType, name string, fields []string) *Variant {
+	v := &Variant{Enum: enum, Name: name, Fields: fields}
+	if len(fields) == 0 {
+		v.unit = &EnumValue{Variant: v}
+	}
+	enum.Variants = append(enum.Variants, v)
+	return v
+}
+
+func (v *Variant) Type() ObjectType { return VARIANT_OBJ }
+func (v *Variant) Inspect() string  { return v.Enum.Name + "." + v.declaration() }
+
+func (v *Variant) declaration() string {
+	if len(v.Fields) == 0 {
+		return v.Name
+	}
+	return v.Name + "(" + strings.Join(v.Fields, ", ") + ")"
+}
+
+// Binding returns what the name of v stands for: v itself, to be called,
+// or its only value if it has no fields.
+func (v *Variant) Binding() Object {
+	if v.unit != nil {
+		return v.unit
+	}
+	return v
+}
+
+// EnumValue is a value of an enum. As for structs, its Type is ENUM and
+// TypeName gives the name of the enum.
+type EnumValue struct {
+	Variant *Variant
+	Values  []Object // parallel to Variant.Fields
+}
+
+func (ev *EnumValue) Type() ObjectType { return ENUM_OBJ }
+
+// Inspect prints ev the way it is made, as in Circle(1) or Empty.
+func (ev *EnumValue) Inspect() string { return ev.inspect(map[*Struct]bool{}) }
+
+func (ev *EnumValue) inspect(printing map[*Struct]bool) string {
+	if len(ev.Values) == 0 {
+		return ev.Variant.Name
+	}
+	values := []string{}
+	for _, val := range ev.Values {
+		values = append(values, inspect(val, printing))
+	}
+	return ev.Variant.Name + "(" + strings.Join(values, ", ") + ")"
+}
+
+func (ev *EnumValue) GetMember(name string) (Object, bool) {
+	for i, field := range ev.Variant.Fields {
+		if field == name {
+			return ev.Values[i], true
+		}
+	}
+	return nil, false
+}
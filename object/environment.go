@@ -1,5 +1,7 @@
 package object
 
+import "sync"
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s}
@@ -11,19 +13,50 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// Environment is safe for concurrent use: every binding goes through a lock.
+// A frozen environment refuses new bindings, so a prelude can be frozen and
+// shared between goroutines that each evaluate in their own enclosed child.
 type Environment struct {
+	mu       sync.RWMutex
 	store    map[string]Object
 	outerEnv *Environment
+	frozen   bool
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
+
 	if !ok && e.outerEnv != nil {
 		obj, ok = e.outerEnv.Get(name)
 	}
 	return obj, ok
 }
+
+// Set binds name to val, returning val, or an Error if the environment is frozen.
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.frozen {
+		return &Error{Kind: ERROR, Message: "cannot bind `" + name + "` in a frozen environment"}
+	}
 	e.store[name] = val
 	return val
 }
+
+// Freeze makes the environment and all its outer environments read only.
+func (e *Environment) Freeze() {
+	for env := e; env != nil; env = env.outerEnv {
+		env.mu.Lock()
+		env.frozen = true
+		env.mu.Unlock()
+	}
+}
+
+func (e *Environment) Frozen() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.frozen
+}
@@ -0,0 +1,113 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	runtimeType = reflect.TypeOf((*Runtime)(nil)).Elem()
+)
+
+// WrapFunc adapts an ordinary Go function into a Builtin. Arguments are
+// converted with ConvertTo and results with FromGo. fn may return nothing,
+// a value, an error, or a value and an error; a non-nil error becomes an
+// Error object. If the first parameter of fn is a Runtime it receives the
+// calling evaluator and is not counted as an argument.
+func WrapFunc(name, doc string, fn interface{}) (*Builtin, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("builtin %s: expected a function, got %T", name, fn)
+	}
+
+	ft := fv.Type()
+	returnsErr := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	switch {
+	case ft.NumOut() > 2,
+		ft.NumOut() == 2 && !returnsErr,
+		ft.NumOut() == 1 && ft.Out(0) != errorType && !canReturn(ft.Out(0)):
+		return nil, fmt.Errorf("builtin %s: unsupported results %s", name, ft)
+	}
+
+	takesRuntime := ft.NumIn() > 0 && ft.In(0) == runtimeType
+	skip := 0
+	if takesRuntime {
+		skip = 1
+	}
+
+	arity := ft.NumIn() - skip
+	if ft.IsVariadic() {
+		arity = VARIADIC
+	}
+
+	call := func(rt Runtime, args []Object) Object {
+		in, err := goArguments(ft, skip, args)
+		if err != nil {
+			return &Error{Kind: TYPE_ERROR, Message: fmt.Sprintf("%s: %s", name, err)}
+		}
+		if takesRuntime {
+			in = append([]reflect.Value{reflect.ValueOf(&rt).Elem()}, in...)
+		}
+
+		out := fv.Call(in)
+		if returnsErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &Error{Kind: ERROR, Message: fmt.Sprintf("%s: %s", name, err)}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return NULL
+		}
+
+		result, err := FromGo(out[0].Interface())
+		if err != nil {
+			return &Error{Kind: TYPE_ERROR, Message: fmt.Sprintf("%s: %s", name, err)}
+		}
+		return result
+	}
+
+	b := &Builtin{Name: name, Arity: arity, Doc: doc}
+	if takesRuntime {
+		b.RuntimeFn = func(rt Runtime, args ...Object) Object { return call(rt, args) }
+	} else {
+		b.Fn = func(args ...Object) Object { return call(nil, args) }
+	}
+	return b, nil
+}
+
+func goArguments(ft reflect.Type, skip int, args []Object) ([]reflect.Value, error) {
+	fixed := ft.NumIn() - skip
+	if ft.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, fmt.Errorf("wrong number of arguments. got=%d, want at least %d", len(args), fixed)
+		}
+	}
+
+	in := make([]reflect.Value, 0, len(args))
+	for idx, arg := range args {
+		var t reflect.Type
+		if idx < fixed {
+			t = ft.In(skip + idx)
+		} else {
+			t = ft.In(skip + fixed).Elem()
+		}
+
+		v, err := ConvertTo(arg, t)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", idx+1, err)
+		}
+		in = append(in, v)
+	}
+	return in, nil
+}
+
+func canReturn(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// MemberGetter is implemented by objects whose members can be read with dot syntax.
+type MemberGetter interface {
+	GetMember(name string) (Object, bool)
+}
+
+// MemberSetter is implemented by objects whose members can be assigned with dot syntax.
+type MemberSetter interface {
+	SetMember(name string, val Object) error
+}
+
+// Native wraps a Go value. Only the allowlisted exported fields and methods
+// are reachable from scripts, under their snake_case names (FirstName is
+// first_name). A field tagged `morty:"alias"` is reachable as alias instead.
+type Native struct {
+	Value   reflect.Value
+	fields  map[string]int
+	methods map[string]string
+}
+
+// NewNative wraps v, exposing the Go fields and methods listed in members.
+// Fields can only be assigned when v is a pointer to a struct.
+func NewNative(v interface{}, members ...string) (*Native, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("cannot wrap nil")
+	}
+
+	n := &Native{Value: rv, fields: map[string]int{}, methods: map[string]string{}}
+
+	structVal := rv
+	if structVal.Kind() == reflect.Pointer && !structVal.IsNil() {
+		structVal = structVal.Elem()
+	}
+
+	for _, member := range members {
+		if structVal.Kind() == reflect.Struct {
+			if field, ok := structVal.Type().FieldByName(member); ok && field.IsExported() && len(field.Index) == 1 {
+				name := field.Tag.Get("morty")
+				if name == "" {
+					name = snakeCase(member)
+				}
+				n.fields[name] = field.Index[0]
+				continue
+			}
+		}
+		if method, ok := rv.Type().MethodByName(member); ok && method.IsExported() {
+			n.methods[snakeCase(member)] = member
+			continue
+		}
+		return nil, fmt.Errorf("%s has no exported field or method %s", rv.Type(), member)
+	}
+
+	return n, nil
+}
+
+func (n *Native) Type() ObjectType { return NATIVE_OBJ }
+func (n *Native) Inspect() string  { return fmt.Sprintf("%v", n.Value.Interface()) }
+
+func (n *Native) GetMember(name string) (Object, bool) {
+	if idx, ok := n.fields[name]; ok {
+		val, err := FromGo(n.structValue().Field(idx).Interface())
+		if err != nil {
+			return &Error{Kind: TYPE_ERROR, Message: fmt.Sprintf("member `%s`: %s", name, err)}, true
+		}
+		return val, true
+	}
+
+	if goName, ok := n.methods[name]; ok {
+		method, err := WrapFunc(name, "", n.Value.MethodByName(goName).Interface())
+		if err != nil {
+			return &Error{Kind: TYPE_ERROR, Message: err.Error()}, true
+		}
+		return method, true
+	}
+
+	return nil, false
+}
+
+func (n *Native) SetMember(name string, val Object) error {
+	idx, ok := n.fields[name]
+	if !ok {
+		return fmt.Errorf("%s has no member `%s`", n.Type(), name)
+	}
+	if n.Value.Kind() != reflect.Pointer {
+		return fmt.Errorf("cannot assign to member `%s` of %s, it does not wrap a pointer", name, n.Type())
+	}
+
+	field := n.structValue().Field(idx)
+	goVal, err := ConvertTo(val, field.Type())
+	if err != nil {
+		return fmt.Errorf("member `%s`: %w", name, err)
+	}
+	field.Set(goVal)
+	return nil
+}
+
+func (n *Native) structValue() reflect.Value {
+	if n.Value.Kind() == reflect.Pointer {
+		return n.Value.Elem()
+	}
+	return n.Value
+}
+
+func snakeCase(name string) string {
+	var out strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// a new word starts at an upper case letter that follows a lower case one,
+			// or that ends an acronym: UserID -> user_id, HTTPServer -> http_server
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				out.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
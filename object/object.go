@@ -2,7 +2,9 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"morty/ast"
 	"strings"
 )
@@ -18,6 +20,18 @@ const (
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
+	NATIVE_OBJ       = "NATIVE"
+	ARRAY_OBJ        = "ARRAY"
+	TASK_OBJ         = "TASK"
+	CHANNEL_OBJ      = "CHANNEL"
+	EXCEPTION_OBJ    = "EXCEPTION"
+	RESULT_OBJ       = "RESULT"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	STRUCT_OBJ       = "STRUCT"
+	TRAIT_OBJ        = "TRAIT"
+	ENUM_OBJ         = "ENUM"
+	VARIANT_OBJ      = "VARIANT"
 )
 
 type Object interface {
@@ -25,6 +39,18 @@ type Object interface {
 	Inspect() string
 }
 
+// TypeName is the type of obj as scripts name it, in messages, annotations
+// and extend: the declared name of a struct or enum value, or else its Type.
+func TypeName(obj Object) ObjectType {
+	switch obj := obj.(type) {
+	case *Struct:
+		return ObjectType(obj.Def.Name)
+	case *EnumValue:
+		return ObjectType(obj.Variant.Enum.Name)
+	}
+	return obj.Type()
+}
+
 // Environment
 
 // Value Objects
@@ -42,6 +68,12 @@ type Boolean struct {
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
 
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
@@ -54,18 +86,91 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Error kinds
+const (
+	ERROR               = "Error" // thrown values and failures without a more specific kind
+	TYPE_ERROR          = "TypeError"
+	NAME_ERROR          = "NameError"
+	ARITY_ERROR         = "ArityError"
+	ZERO_DIVISION_ERROR = "ZeroDivisionError"
+	MATCH_ERROR         = "MatchError"
+
+	// errors stopping the whole evaluation, which scripts cannot catch
+	CANCELLED_ERROR        = "CancelledError"
+	STEP_LIMIT_ERROR       = "StepLimitError"
+	ALLOCATION_LIMIT_ERROR = "AllocationLimitError"
+	OUTPUT_LIMIT_ERROR     = "OutputLimitError"
+	PERMISSION_ERROR       = "PermissionError"
+)
+
+// Error unwinds evaluation until it is caught by a try expression or reaches the host.
 type Error struct {
+	Kind    string
 	Message string
+	Value   Object    // the value given to throw, if any
+	Node    ast.Noder // the innermost node whose evaluation failed, if known
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR:" + e.Message }
 
+// Catchable reports whether a try expression may catch the error.
+func (e *Error) Catchable() bool {
+	switch e.Kind {
+	case CANCELLED_ERROR, STEP_LIMIT_ERROR, ALLOCATION_LIMIT_ERROR, OUTPUT_LIMIT_ERROR:
+		return false
+	}
+	return true
+}
+
+// Exception is a caught Error, an ordinary value that no longer unwinds.
+type Exception struct {
+	Kind    string
+	Message string
+	Value   Object
+}
+
+func (ex *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (ex *Exception) Inspect() string  { return ex.Kind + ": " + ex.Message }
+
+func (ex *Exception) GetMember(name string) (Object, bool) {
+	switch name {
+	case "kind":
+		return &String{Value: ex.Kind}, true
+	case "message":
+		return &String{Value: ex.Message}, true
+	case "value":
+		if ex.Value == nil {
+			return NULL, true
+		}
+		return ex.Value, true
+	}
+	return nil, false
+}
+
+// Result is an error as an ordinary value: ok(value) or err(value).
+type Result struct {
+	Ok    bool
+	Value Object
+}
+
+func (r *Result) Type() ObjectType { return RESULT_OBJ }
+func (r *Result) Inspect() string  { return r.inspect(map[*Struct]bool{}) }
+
+func (r *Result) inspect(printing map[*Struct]bool) string {
+	if r.Ok {
+		return "ok(" + inspect(r.Value, printing) + ")"
+	}
+	return "err(" + inspect(r.Value, printing) + ")"
+}
+
 type Function struct {
-	Name       *ast.Identifier
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Name           *ast.Identifier // of a named fn, or of the let a fn literal is bound by; nil otherwise
+	Parameters     []*ast.Identifier
+	ParameterTypes []ast.TypeExpression // checked when the function is called
+	ReturnType     ast.TypeExpression
+	Body           *ast.BlockStatement
+	Env            *Environment
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -87,6 +192,39 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Quote is an unevaluated piece of code, as returned by quote(expr).
+type Quote struct {
+	Node ast.Noder
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.ToString() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.ToString())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.ToString())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
 type String struct {
 	Value string
 }
@@ -94,10 +232,48 @@ type String struct {
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string  { return a.inspect(map[*Struct]bool{}) }
+
+func (a *Array) inspect(printing map[*Struct]bool) string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, inspect(el, printing))
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
 type BuiltinFunction func(args ...Object) Object
 
+// Runtime is the running evaluator as seen by builtins that need to call back into it.
+// It may be used from several goroutines at once.
+type Runtime interface {
+	Call(fn Object, args ...Object) Object
+	Output() io.Writer
+	Context() context.Context
+}
+
+// RuntimeFunction is a builtin that receives the Runtime it is called from,
+// so it can apply function arguments.
+type RuntimeFunction func(rt Runtime, args ...Object) Object
+
+// VARIADIC is the Arity of builtins accepting any number of arguments.
+const VARIADIC = -1
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Name  string
+	Arity int
+	Doc   string
+	Fn    BuiltinFunction
+	// RuntimeFn is used instead of Fn when set.
+	RuntimeFn RuntimeFunction
+	// Capabilities lists what the evaluator must grant before scripts can see the builtin.
+	Capabilities []string
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
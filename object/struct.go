@@ -0,0 +1,101 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StructType is a struct declaration, such as struct Point { x, y }.
+type StructType struct {
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_OBJ }
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+// Field returns the index of the named field, or -1.
+func (st *StructType) Field(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Struct is a value of a struct type. Its Type is STRUCT whatever the
+// script named it, so that no struct passes for a builtin type; TypeName
+// gives the declared name.
+type Struct struct {
+	Def    *StructType
+	Values []Object // parallel to Def.Fields; read through Fields once shared
+	mu     sync.RWMutex
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+
+// Inspect prints s as a struct literal, as in Point{x: 1, y: 2}. A struct
+// reached again from its own fields is printed as Point{...}.
+func (s *Struct) Inspect() string { return s.inspect(map[*Struct]bool{}) }
+
+func (s *Struct) inspect(printing map[*Struct]bool) string {
+	if printing[s] {
+		return s.Def.Name + "{...}"
+	}
+	printing[s] = true
+	defer delete(printing, s)
+
+	values := s.Fields()
+	fields := []string{}
+	for i, field := range s.Def.Fields {
+		fields = append(fields, field+": "+inspect(values[i], printing))
+	}
+	return s.Def.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// inspector is an object holding other objects, which may lead back to a
+// struct being printed.
+type inspector interface {
+	inspect(printing map[*Struct]bool) string
+}
+
+// inspect prints obj within the structs in printing, which are printed
+// again as Name{...}. Each call of Inspect has its own printing, so that
+// tasks printing the same struct do not see each other's.
+func inspect(obj Object, printing map[*Struct]bool) string {
+	if in, ok := obj.(inspector); ok {
+		return in.inspect(printing)
+	}
+	return obj.Inspect()
+}
+
+// Fields returns a copy of the values of s, which tasks may be setting.
+func (s *Struct) Fields() []Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Object(nil), s.Values...)
+}
+
+func (s *Struct) GetMember(name string) (Object, bool) {
+	if i := s.Def.Field(name); i >= 0 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.Values[i], true
+	}
+	return nil, false
+}
+
+func (s *Struct) SetMember(name string, val Object) error {
+	i := s.Def.Field(name)
+	if i < 0 {
+		return fmt.Errorf("%s has no member `%s`", s.Def.Name, name)
+	}
+	s.mu.Lock()
+	s.Values[i] = val
+	s.mu.Unlock()
+	return nil
+}
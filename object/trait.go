@@ -0,0 +1,21 @@
+package object
+
+import "strings"
+
+// Trait is a trait declaration, such as trait Shape { area, perimeter },
+// naming the methods a type must have.
+type Trait struct {
+	Name    string
+	Methods []string
+}
+
+func (t *Trait) Type() ObjectType { return TRAIT_OBJ }
+func (t *Trait) Inspect() string {
+	return "trait " + t.Name + " { " + strings.Join(t.Methods, ", ") + " }"
+}
+
+// MethodName is the name environments bind the method name of values of
+// typ to, as added by extend. No identifier can spell it.
+func MethodName(typ ObjectType, name string) string {
+	return string(typ) + "." + name
+}
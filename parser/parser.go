@@ -3,23 +3,37 @@ package parser
 import (
 	"fmt"
 	"morty/ast"
+	"morty/diagnostic"
 	"morty/lexer"
 	"morty/token"
 	"strconv"
 )
 
+// Tokenizer supplies the tokens a Parser reads, usually a *lexer.Lexer.
+type Tokenizer interface {
+	NextToken() token.Token
+	Comments() []token.Token
+}
+
 type Parser struct {
-	lex       *lexer.Lexer
+	lex       Tokenizer
 	curToken  token.Token
 	peekToken token.Token
-	errors    []string
+	diags     []diagnostic.Diagnostic
+	panicking bool // an error was reported in the current statement
+	inGuard   bool // parsing the guard of a case, which a { ends
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lex: l, errors: []string{}}
+	return NewFromTokenizer(l)
+}
+
+// NewFromTokenizer returns a parser reading the tokens of t.
+func NewFromTokenizer(t Tokenizer) *Parser {
+	p := &Parser{lex: t}
 	p.nextToken()
 	p.nextToken()
 
@@ -34,6 +48,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFuncionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -45,6 +63,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.QUESTION, p.parsePostfixExpression)
+	p.registerInfix(token.LBRACE, p.parseStructLiteral)
 
 	return p
 }
@@ -54,26 +76,95 @@ func (p *Parser) nextToken() {
 	p.peekToken = p.lex.NextToken()
 }
 
+// Errors returns the messages of the diagnostics.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := []string{}
+	for _, d := range p.diags {
+		errors = append(errors, d.Message)
+	}
+	return errors
+}
+
+// Diagnostics returns the syntax errors found so far, with their positions.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diags
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	d := diagnostic.Errorf(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.peekToken),
+		"expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	d.Primary.Message = fmt.Sprintf("expected %s", t)
+	if closing[t] {
+		end := diagnostic.TokenSpan(p.curToken).End
+		d.Suggestions = append(d.Suggestions, diagnostic.Suggestion{
+			Message:     fmt.Sprintf("insert %s after %s", t, p.curToken.Literal),
+			Span:        diagnostic.Span{Start: end, End: end},
+			Replacement: string(t),
+		})
+	}
+	p.report(d)
+}
+
+var closing = map[token.TokenType]bool{token.RPAREN: true, token.RBRACKET: true, token.RBRACE: true}
+
+// report records a syntax error, unless one was already reported for the
+// current statement: what follows the first error is usually just its fallout.
+func (p *Parser) report(d diagnostic.Diagnostic) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.diags = append(p.diags, d)
 }
 
+func (p *Parser) errorf(code diagnostic.Code, tok token.Token, format string, a ...interface{}) {
+	p.report(diagnostic.Errorf(code, diagnostic.TokenSpan(tok), format, a...))
+}
+
+// synchronize skips the rest of a statement that failed to parse, stopping
+// after a semicolon, on the next let, return, throw or struct, or on a closing brace
+// or EOF, so that parsing can resume at the next statement.
+func (p *Parser) synchronize() {
+	p.panicking = false
+	for {
+		switch p.curToken.Type {
+		case token.EOF, token.RBRACE:
+			return
+		case token.SEMICOLON:
+			p.nextToken()
+			return
+		}
+
+		p.nextToken()
+		switch p.curToken.Type {
+		case token.LET, token.RETURN, token.THROW, token.STRUCT, token.EXTEND, token.TRAIT, token.ENUM:
+			return
+		}
+	}
+}
+
+// ParseProgram parses the whole input. Statements that fail to parse are
+// reported in Errors and left out of the program, so that its nodes have
+// no nil fields.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt != nil && !p.panicking {
 			program.Statements = append(program.Statements, stmt)
 		}
+		if p.panicking {
+			p.synchronize()
+			if p.curTokenIs(token.RBRACE) {
+				p.nextToken() // a brace closing nothing
+			}
+			continue
+		}
 		p.nextToken()
 	}
+	program.Comments = p.lex.Comments()
 	return program
 }
 
@@ -82,14 +173,36 @@ func (p *Parser) parseStatement() ast.Statement {
 		return nil
 	}
 
+	// return untyped nils, so that callers can tell a failed statement apart
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.STRUCT:
+		if stmt := p.parseStructStatement(); stmt != nil {
+			return stmt
+		}
+	case token.EXTEND:
+		if stmt := p.parseExtendStatement(); stmt != nil {
+			return stmt
+		}
+	case token.TRAIT:
+		if stmt := p.parseTraitStatement(); stmt != nil {
+			return stmt
+		}
+	case token.ENUM:
+		if stmt := p.parseEnumStatement(); stmt != nil {
+			return stmt
+		}
 	default:
 		return p.parseExpressionStatement()
 	}
+	return nil
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
@@ -102,6 +215,15 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Type = p.parseType()
+		if stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -177,7 +299,8 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 const (
 	_      int = iota // value is 0
-	LOWEST            // value from 0 to 7 is assigned to each
+	LOWEST            // value from 0 to 8 is assigned to each
+	ASSIGN
 	EQUALS
 	LESSGREATER
 	SUM
@@ -187,8 +310,14 @@ const (
 )
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse func for %s found", t)
-	p.errors = append(p.errors, msg)
+	d := diagnostic.Errorf(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.curToken),
+		"no prefix parse func for %s found", t)
+	d.Primary.Message = "expected an expression"
+	if t == token.ILLEGAL {
+		d.Code = diagnostic.IllegalCharacter
+		d.Primary.Message = "illegal character"
+	}
+	p.report(d)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -201,7 +330,8 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 	leftExp := prefixFn()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	// after an error leftExp may be nil, which no infix parse func expects
+	for !p.panicking && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -225,8 +355,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(diagnostic.InvalidInteger, p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -250,6 +379,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 var precedences = map[token.TokenType]int{
+	token.ASSIGN:     ASSIGN,
 	token.EQUALTO:    EQUALS,
 	token.NOTEQUALTO: EQUALS,
 	token.LT:         LESSGREATER,
@@ -259,9 +389,15 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:      PRODUCT,
 	token.ASTERISK:   PRODUCT,
 	token.LPAREN:     CALL,
+	token.DOT:        CALL,
+	token.QUESTION:   CALL,
+	token.LBRACE:     CALL,
 }
 
 func (p *Parser) peekPrecedence() int {
+	if p.peekTokenIs(token.LBRACE) && (!isStructName(p.curToken) || p.inGuard) {
+		return LOWEST // a block, as in if (x) {, or a missing ) before it
+	}
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
 	}
@@ -343,6 +479,141 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken, Cases: []*ast.MatchCase{}}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for {
+		if !p.expectPeek(token.CASE) {
+			return nil
+		}
+		c := p.parseMatchCase()
+		if c == nil {
+			return nil
+		}
+		expression.Cases = append(expression.Cases, c)
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+	}
+
+	p.nextToken()
+	expression.Rbrace = p.curToken
+
+	return expression
+}
+
+func (p *Parser) parseMatchCase() *ast.MatchCase {
+	c := &ast.MatchCase{Token: p.curToken}
+
+	p.nextToken()
+	if c.Pattern = p.parsePattern(); c.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		p.inGuard = true
+		c.Guard = p.parseExpression(LOWEST)
+		p.inGuard = false
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	c.Body = p.parseBlockStatement()
+
+	return c
+}
+
+// parsePattern parses a literal, _, a name, a variant such as Rect(w, h)
+// or Empty, or an array of patterns.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if ident.Value == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		if !isStructName(p.curToken) {
+			return &ast.BindingPattern{Token: p.curToken, Name: ident}
+		}
+
+		pattern := &ast.VariantPattern{Token: p.curToken, Name: ident, Fields: []ast.Pattern{}}
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if pattern.Fields = p.parsePatterns(token.RPAREN); pattern.Fields == nil {
+				return nil
+			}
+		}
+		return pattern
+
+	case token.LBRACKET:
+		pattern := &ast.ArrayPattern{Token: p.curToken}
+		if pattern.Elements = p.parsePatterns(token.RBRACKET); pattern.Elements == nil {
+			return nil
+		}
+		return pattern
+
+	case token.INT, token.STRING, token.TRUE, token.FALSE, token.MINUS:
+		pattern := &ast.LiteralPattern{Token: p.curToken}
+		pattern.Value = p.parseExpression(PREFIX)
+		switch value := pattern.Value.(type) {
+		case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+			return pattern
+		case *ast.PrefixExpression:
+			if _, ok := value.Right.(*ast.IntegerLiteral); ok {
+				return pattern
+			}
+			if value.Right != nil {
+				p.errorf(diagnostic.UnexpectedToken, pattern.Token, "expected an integer after %s in a pattern, got %s instead", value.Operator, value.Right.ToString())
+			}
+		}
+		return nil
+	}
+
+	p.errorf(diagnostic.UnexpectedToken, p.curToken, "expected a pattern, got %s instead", p.curToken.Literal)
+	return nil
+}
+
+// parsePatterns parses patterns separated by commas up to end, a trailing comma allowed.
+func (p *Parser) parsePatterns(end token.TokenType) []ast.Pattern {
+	patterns := []ast.Pattern{}
+
+	for !p.peekTokenIs(end) {
+		p.nextToken()
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return nil
+		}
+		patterns = append(patterns, pattern)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+	return patterns
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -351,11 +622,24 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt != nil && !p.panicking {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
+
+	if p.curTokenIs(token.EOF) {
+		d := diagnostic.Errorf(diagnostic.UnclosedDelimiter, diagnostic.TokenSpan(p.curToken),
+			"expected next token to be }, got EOF instead")
+		d.Primary.Message = "expected }"
+		d.Secondary = append(d.Secondary, diagnostic.Label{Span: diagnostic.TokenSpan(block.Token), Message: "unclosed { opened here"})
+		p.report(d)
+	}
 
 	return block
 }
@@ -372,7 +656,19 @@ func (p *Parser) parseFuncionLiteral() ast.Expression {
 		return nil
 	}
 
-	function.Parameters = p.parseFunctionParameters()
+	function.Parameters, function.ParameterTypes = p.parseFunctionParameters()
+	if function.Parameters == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		function.ReturnType = p.parseType()
+		if function.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -383,42 +679,178 @@ func (p *Parser) parseFuncionLiteral() ast.Expression {
 	return function
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	macro := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	var types []ast.TypeExpression
+	macro.Parameters, types = p.parseFunctionParameters()
+	for i, typ := range types {
+		if typ != nil {
+			p.errorf(diagnostic.UnexpectedToken, macro.Parameters[i].Token, "macro parameter %s cannot have a type annotation", macro.Parameters[i].Value)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	macro.Body = p.parseBlockStatement()
+
+	return macro
+}
+
+// parseFunctionParameters returns the parameters and their annotations,
+// which are nil when no parameter has one.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.TypeExpression) {
 	identifiers := []*ast.Identifier{}
+	types := []ast.TypeExpression{}
+	typed := false
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil
 	}
-	p.nextToken()
-	param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, param)
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	for {
 		p.nextToken()
 		param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, param)
+
+		var typ ast.TypeExpression
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if typ = p.parseType(); typ == nil {
+				return nil, nil
+			}
+			typed = true
+		}
+		types = append(types, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	if !typed {
+		return identifiers, nil
+	}
+	return identifiers, types
+}
+
+// parseType parses a type annotation starting at the current token:
+// a name, [T], fn(T, ...) -> R, or alternatives joined by |.
+func (p *Parser) parseType() ast.TypeExpression {
+	typ := p.parseSingleType()
+	if typ == nil || !p.peekTokenIs(token.PIPE) {
+		return typ
+	}
+
+	union := &ast.UnionType{Token: p.peekToken, Types: []ast.TypeExpression{typ}}
+	for p.peekTokenIs(token.PIPE) {
+		p.nextToken()
+		p.nextToken()
+		typ := p.parseSingleType()
+		if typ == nil {
+			return nil
+		}
+		union.Types = append(union.Types, typ)
+	}
+	return union
+}
+
+func (p *Parser) parseSingleType() ast.TypeExpression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+
+	case token.LBRACKET:
+		array := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if array.Element = p.parseType(); array.Element == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return array
+
+	case token.FUNCTION:
+		fn := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeExpression{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+		} else {
+			for {
+				p.nextToken()
+				param := p.parseType()
+				if param == nil {
+					return nil
+				}
+				fn.Parameters = append(fn.Parameters, param)
+				if !p.peekTokenIs(token.COMMA) {
+					break
+				}
+				p.nextToken()
+			}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if p.peekTokenIs(token.ARROW) {
+			p.nextToken()
+			p.nextToken()
+			if fn.Return = p.parseType(); fn.Return == nil {
+				return nil
+			}
+		}
+		return fn
+
+	case token.LPAREN:
+		p.nextToken()
+		typ := p.parseType()
+		if typ == nil || !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return typ
+	}
+
+	p.errorf(diagnostic.UnexpectedToken, p.curToken, "expected a type, got %s instead", p.curToken.Type)
+	return nil
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
+
+	return array
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args := []ast.Expression{}
 
-	if p.peekTokenIs(token.RPAREN) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return args
 	}
@@ -435,7 +867,7 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		args = append(args, a)
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
@@ -445,3 +877,328 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: target}
+
+	if _, ok := target.(*ast.MemberExpression); !ok {
+		d := diagnostic.Errorf(diagnostic.InvalidAssignTarget, diagnostic.NodeSpan(target),
+			"invalid assignment target %s", target.ToString())
+		d.Notes = append(d.Notes, "only members such as a.b can be assigned, use let to bind names")
+		p.report(d)
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST) // right associative: a.b = c.d = e
+
+	return exp
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorf(diagnostic.MissingHandler, expression.Token, "expected catch or finally after try block")
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	return &ast.PostfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
+}
+
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if stmt.Name = p.parseTypeName("struct"); stmt.Name == nil {
+		return nil
+	}
+	if stmt.Fields, stmt.Rbrace = p.parseNameList(); stmt.Fields == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseTraitStatement() *ast.TraitStatement {
+	stmt := &ast.TraitStatement{Token: p.curToken}
+
+	if stmt.Name = p.parseTypeName("trait"); stmt.Name == nil {
+		return nil
+	}
+	if stmt.Methods, stmt.Rbrace = p.parseNameList(); stmt.Methods == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.curToken, Variants: []*ast.Identifier{}, Fields: [][]*ast.Identifier{}}
+
+	if stmt.Name = p.parseTypeName("enum"); stmt.Name == nil {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		variant := p.parseTypeName("variant")
+		if variant == nil {
+			return nil
+		}
+		fields := []*ast.Identifier{}
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			var ok bool
+			if fields, ok = p.parseFieldNames(); !ok {
+				return nil
+			}
+		}
+		stmt.Variants = append(stmt.Variants, variant)
+		stmt.Fields = append(stmt.Fields, fields)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseFieldNames parses the names of the fields of a variant up to ).
+func (p *Parser) parseFieldNames() ([]*ast.Identifier, bool) {
+	fields := []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil, false
+		}
+		fields = append(fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return fields, p.expectPeek(token.RPAREN)
+}
+
+// parseTypeName parses the name of the struct, trait, enum or variant being declared.
+func (p *Parser) parseTypeName(what string) *ast.Identifier {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	if !isStructName(p.curToken) {
+		p.errorf(diagnostic.UnexpectedToken, p.curToken, "%s name %s must start with an uppercase letter", what, p.curToken.Literal)
+		return nil
+	}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseNameList parses { a, b }, a trailing comma allowed, and returns the
+// names and the closing brace.
+func (p *Parser) parseNameList() ([]*ast.Identifier, token.Token) {
+	names := []*ast.Identifier{}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil, token.Token{}
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil, token.Token{}
+		}
+		names = append(names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil, token.Token{}
+	}
+	return names, p.curToken
+}
+
+// parseExtendStatement parses extend Type: Trait, ... { fn name(self, ...) { ... } ... }.
+func (p *Parser) parseExtendStatement() *ast.ExtendStatement {
+	stmt := &ast.ExtendStatement{Token: p.curToken, Traits: []*ast.Identifier{}, Methods: []*ast.FunctionLiteral{}}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Type = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Traits = append(stmt.Traits, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+		method, _ := p.parseFuncionLiteral().(*ast.FunctionLiteral)
+		if method == nil {
+			return nil
+		}
+		if method.Name == nil {
+			p.errorf(diagnostic.UnexpectedToken, method.Token, "method of %s must have a name", stmt.Type.Value)
+			return nil
+		}
+		if len(method.Parameters) == 0 {
+			p.errorf(diagnostic.UnexpectedToken, method.Name.Token, "method %s must take the receiver as its first parameter", method.Name.Value)
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, method)
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// isStructName reports whether tok can name a struct or trait: an identifier starting
+// with an uppercase letter, which tells Point{x: 1} apart from if (x) { 1 }.
+func isStructName(tok token.Token) bool {
+	return tok.Type == token.IDENT && tok.Literal[0] >= 'A' && tok.Literal[0] <= 'Z'
+}
+
+func (p *Parser) parseStructLiteral(name ast.Expression) ast.Expression {
+	lit := &ast.StructLiteral{Token: p.curToken, Fields: []*ast.Identifier{}, Values: []ast.Expression{}}
+
+	ident, ok := name.(*ast.Identifier)
+	if !ok {
+		p.errorf(diagnostic.UnexpectedToken, p.curToken, "expected a struct name before {, got %s", name.ToString())
+		return nil
+	}
+	lit.Name = ident
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		lit.Fields = append(lit.Fields, field)
+		lit.Values = append(lit.Values, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken() // a trailing comma is allowed
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	lit.Rbrace = p.curToken
+
+	return lit
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"morty/ast"
+	"morty/diagnostic"
 	"morty/lexer"
 	"morty/token"
 	"testing"
@@ -73,7 +74,7 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	}
 
 	if letStmt.Name.TokenLiteral() != name {
-		t.Errorf("s.Name not '%s'. got=%s", name, letStmt.Name)
+		t.Errorf("s.Name not '%s'. got=%s", name, letStmt.Name.TokenLiteral())
 		return false
 	}
 	return true
@@ -312,6 +313,13 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a.b.c", "a.b.c"},
+		{"f(a)? + 1", "((f(a)?) + 1)"},
+		{"-a.b?", "(-(a.b?))"},
+		{"a + b.c * d", "(a + (b.c * d))"},
+		{"user.greet(a + b).name", "user.greet((a + b)).name"},
+		{"-a.b", "(-a.b)"},
+		{"a.b = c.d = 1 + 2", "a.b = c.d = (1 + 2)"},
 	}
 
 	for _, tt := range tests {
@@ -705,3 +713,468 @@ func TestStringLiteralExpression(t *testing.T) {
 		t.Errorf("literal.Value not %q, got=%q", "hello world", literal.Value)
 	}
 }
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("a + b = 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "invalid assignment target (a + b)" {
+		t.Fatalf("wrong parser errors, got=%q", errors)
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral, got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3, got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	input := `try { risky(x) } catch (e) { e.message } finally { cleanup() }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements, got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression, got=%T", stmt.Expression)
+	}
+
+	if exp.Block.ToString() != "risky(x)" {
+		t.Errorf("wrong try block, got=%q", exp.Block.ToString())
+	}
+	if !testIdentifier(t, exp.CatchParam, "e") {
+		return
+	}
+	if exp.Catch.ToString() != "e.message" {
+		t.Errorf("wrong catch block, got=%q", exp.Catch.ToString())
+	}
+	if exp.Finally == nil || exp.Finally.ToString() != "cleanup()" {
+		t.Errorf("wrong finally block, got=%+v", exp.Finally)
+	}
+}
+
+func TestThrowStatementParsing(t *testing.T) {
+	l := lexer.New(`throw "boom" + x;`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ThrowStatement, got=%T", program.Statements[0])
+	}
+	if stmt.ToString() != "throw (boom + x);" {
+		t.Errorf("wrong throw statement, got=%q", stmt.ToString())
+	}
+}
+
+func TestTryWithoutHandlers(t *testing.T) {
+	l := lexer.New("try { 1 }")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "expected catch or finally after try block" {
+		t.Errorf("wrong parser errors, got=%q", errors)
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements, got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral, got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong, want 2, got=%d\n", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if macro.Body.ToString() != "(x + y)" {
+		t.Errorf("wrong macro body, got=%q", macro.Body.ToString())
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let y: int | null = 1;", "let y: int | null = 1;"},
+		{"fn add(a: int, b: int) -> int { a + b }", "fn(a: int, b: int) -> int (a + b)"},
+		{"fn(a, b: bool) { a }", "fn(a, b: bool) a"},
+		{"let f: fn(int, int) -> bool = 1;", "let f: fn(int, int) -> bool = 1;"},
+		{"let g: (fn() -> int) | null = 1;", "let g: (fn() -> int) | null = 1;"},
+		{"fn(f: fn(int) -> [int | null]) -> fn() { f }", "fn(f: fn(int) -> [int | null]) -> fn() f"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.ToString() != tt.expected {
+			t.Errorf("wrong program for %q, want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+}
+
+func TestFunctionParameterTypes(t *testing.T) {
+	l := lexer.New("fn(a: int, b) {}; fn(a, b) {}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	typed := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(typed.ParameterTypes) != 2 || typed.ParameterType(0).ToString() != "int" || typed.ParameterType(1) != nil {
+		t.Errorf("wrong parameter types, got=%v", typed.ParameterTypes)
+	}
+
+	untyped := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if untyped.ParameterTypes != nil || untyped.ParameterType(1) != nil {
+		t.Errorf("parameters without annotations have types, got=%v", untyped.ParameterTypes)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 5;", "expected a type, got = instead"},
+		{"let x: [int = 5;", "expected next token to be ], got = instead"},
+		{"fn(a: 1) {}", "expected a type, got INT instead"},
+		{"macro(a: int) { a }", "macro parameter a cannot have a type annotation"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q, want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestStructParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }"},
+		{"struct Empty {}", "struct Empty {  }"},
+		{"struct Line {\n\tfrom,\n\tto,\n}", "struct Line { from, to }"},
+		{"Point{x: 1, y: 2 * 3}", "Point{x: 1, y: (2 * 3)}"},
+		{"Point{}", "Point{}"},
+		{"let p = Line{from: Point{x: 1, y: 2}, to: origin,}; p.from.x", "let p = Line{from: Point{x: 1, y: 2}, to: origin};p.from.x"},
+		{"Point{x: 1}.x + 1", "(Point{x: 1}.x + 1)"},
+		{"if (x) { Point{x: 1} }", "ifx Point{x: 1}"},
+		{"f(Point{x: a.b})", "f(Point{x: a.b})"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.ToString() != tt.expected {
+			t.Errorf("wrong program for %q, want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct point { x }", "struct name point must start with an uppercase letter"},
+		{"struct Point { x y }", "expected next token to be }, got IDENT instead"},
+		{"struct Point { 1 }", "expected next token to be IDENT, got INT instead"},
+		{"Point{x 1}", "expected next token to be :, got INT instead"},
+		{"a.Point{x: 1}", "expected a struct name before {, got a.Point"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q, want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMethodParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc".upper()`, "abc.upper()"},
+		{`s.split(",")[0]`, "s.split(,)[0]"},
+		{"n.abs() + 1", "(n.abs() + 1)"},
+		{"trait Shape { area, perimeter, }", "trait Shape { area, perimeter }"},
+		{`extend STRING { fn shout(self) { self + "!" } }`, "extend STRING { fn shout(self) (self + !) }"},
+		{"extend Point: Shape, Show { fn area(self) { 0 }; fn show(self) { \"p\" } }", "extend Point: Shape, Show { fn area(self) 0 fn show(self) p }"},
+		{"extend Point {}", "extend Point { }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.ToString() != tt.expected {
+			t.Errorf("wrong program for %q, want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+}
+
+func TestMethodErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"trait shape { area }", "trait name shape must start with an uppercase letter"},
+		{"extend STRING { let x = 1; }", "expected next token to be FUNCTION, got LET instead"},
+		{"extend STRING { fn (self) { self } }", "method of STRING must have a name"},
+		{"extend STRING { fn shout() { 1 } }", "method shout must take the receiver as its first parameter"},
+		{"extend Point: { fn f(self) { 1 } }", "expected next token to be IDENT, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q, want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Shape { Circle(r), Rect(w, h), Empty }", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{"enum Option {\n\tSome(v),\n\tNone,\n}", "enum Option { Some(v), None }"},
+		{"enum Unit { U() }", "enum Unit { U }"},
+		{"match (x) { case 1 { \"one\" } case -1 { 0 } case _ { x } }", "matchx { case 1 one case (-1) 0 case _ x }"},
+		{"match (s) { case Circle(r) { r * r } case Rect(w, h) if w == h { w * w } case Empty { 0 } }",
+			"matchs { case Circle(r) (r * r) case Rect(w, h) if (w == h) (w * w) case Empty 0 }"},
+		{"match (p) { case [a, [b, _], \"c\", true,] { a } }", "matchp { case [a, [b, _], c, true] a }"},
+		{"match (x) { case n if n > Limit { n } }", "matchx { case n if (n > Limit) n }"},
+		{"let y = match (x) { case Some(Pair(a, b)) { a + b } case None { 0 } }; y", "let y = matchx { case Some(Pair(a, b)) (a + b) case None 0 };y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.ToString() != tt.expected {
+			t.Errorf("wrong program for %q, want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum shape { Circle }", "enum name shape must start with an uppercase letter"},
+		{"enum Shape { circle(r) }", "variant name circle must start with an uppercase letter"},
+		{"enum Shape { Circle(1) }", "expected next token to be IDENT, got INT instead"},
+		{"match (x) {}", "expected next token to be CASE, got } instead"},
+		{"match x { case 1 { 1 } }", "expected next token to be (, got IDENT instead"},
+		{"match (x) { case 1 + 2 { 1 } }", "expected next token to be {, got + instead"},
+		{"match (x) { case -y { 1 } }", "expected an integer after - in a pattern, got y instead"},
+		{"match (x) { case fn() { 1 } { 1 } }", "expected a pattern, got fn instead"},
+		{"match (x) { case Rect(w { 1 } }", "expected next token to be ), got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q, want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedStmts  []string
+	}{
+		{
+			"let = 5; let y = 10;",
+			[]string{"expected next token to be IDENT, got = instead"},
+			[]string{"let y = 10;"},
+		},
+		{
+			"let x 5; let y = ; let z = 1;",
+			[]string{
+				"expected next token to be =, got INT instead",
+				"no prefix parse func for ; found",
+			},
+			[]string{"let z = 1;"},
+		},
+		{
+			"if (x { 1 } let a = 2; return a;",
+			[]string{"expected next token to be ), got { instead"},
+			[]string{"let a = 2;", "return a;"},
+		},
+		{
+			"fn() { let = 1; let b = 2; b } let c = ;",
+			[]string{
+				"expected next token to be IDENT, got = instead",
+				"no prefix parse func for ; found",
+			},
+			[]string{"fn() let b = 2;b"},
+		},
+		{
+			"} let a = 1;",
+			[]string{"no prefix parse func for } found"},
+			[]string{"let a = 1;"},
+		},
+		{
+			// the infix loop stops at an error, rather than handing a nil left to =, [ or +
+			"fn = 1; fn [1]; if + 1; let b = 2;",
+			[]string{
+				"expected next token to be (, got = instead",
+				"expected next token to be (, got [ instead",
+				"expected next token to be (, got + instead",
+			},
+			[]string{"let b = 2;"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: wrong number of errors. want=%d, got=%d: %q",
+				tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("%q: errors[%d] wrong. want=%q, got=%q", tt.input, i, msg, errors[i])
+			}
+		}
+
+		stmts := []string{}
+		for _, s := range program.Statements {
+			stmts = append(stmts, s.ToString())
+		}
+		if len(stmts) != len(tt.expectedStmts) {
+			t.Errorf("%q: wrong statements. want=%q, got=%q", tt.input, tt.expectedStmts, stmts)
+			continue
+		}
+		for i, want := range tt.expectedStmts {
+			if stmts[i] != want {
+				t.Errorf("%q: statement %d wrong. want=%q, got=%q", tt.input, i, want, stmts[i])
+			}
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input  string
+		code   diagnostic.Code
+		line   int
+		column int
+	}{
+		{"let x = 5;\nlet = 1;", diagnostic.UnexpectedToken, 2, 5},
+		{"1 + ;", diagnostic.UnexpectedToken, 1, 5},
+		{"let a = 1 @ 2;", diagnostic.IllegalCharacter, 1, 11},
+		{"let a = 99999999999999999999;", diagnostic.InvalidInteger, 1, 9},
+		{"  a + b = c;", diagnostic.InvalidAssignTarget, 1, 3},
+		{"try { 1 }", diagnostic.MissingHandler, 1, 1},
+		{"fn() {\n  1", diagnostic.UnclosedDelimiter, 2, 4},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diags := p.Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("%q: wrong number of diagnostics. want=1, got=%d: %q", tt.input, len(diags), p.Errors())
+			continue
+		}
+		d := diags[0]
+		if d.Code != tt.code {
+			t.Errorf("%q: wrong code. want=%s, got=%s", tt.input, tt.code, d.Code)
+		}
+		if start := d.Primary.Span.Start; start.Line != tt.line || start.Column != tt.column {
+			t.Errorf("%q: wrong position. want=%d:%d, got=%d:%d", tt.input, tt.line, tt.column, start.Line, start.Column)
+		}
+		if p.Errors()[0] != d.Message {
+			t.Errorf("%q: Errors() does not match the diagnostic, got=%q", tt.input, p.Errors()[0])
+		}
+	}
+}
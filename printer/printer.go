@@ -0,0 +1,635 @@
+// Package printer prints ASTs back as Morty source that the parser accepts.
+package printer
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"morty/ast"
+	"morty/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Binding strengths, mirroring the precedences of the parser.
+const (
+	_ int = iota
+	lowest
+	assign
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+	atom // literals, identifiers and expressions that end with a block
+)
+
+var infixPrecedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+const (
+	maxWidth = 80 // argument lists that would go past it are broken one per line
+	tabWidth = 4
+)
+
+// Sprint returns the source for node.
+func Sprint(node ast.Noder) string {
+	p := &printer{}
+	if program, ok := node.(*ast.Program); ok {
+		p.comments = program.Comments
+	}
+	p.node(node)
+	return p.buf.String()
+}
+
+// Fprint writes the source for node to w. Blocks are indented with tabs,
+// every statement ends with a semicolon and runs of blank lines between
+// statements are kept as one. The comments of a parsed program are printed
+// before the statement that follows them, or at the end of the line they
+// trail; a call, array or struct literal with comments among its items is
+// printed one item per line to keep them in place. Strings containing a double quote cannot be printed faithfully,
+// since the language has no escapes.
+func Fprint(w io.Writer, node ast.Noder) error {
+	_, err := io.WriteString(w, Sprint(node))
+	return err
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	indent   int
+	comments []token.Token // comments not printed yet
+	lastLine int           // source line of the last statement or comment printed
+	empty    bool          // nothing printed yet in the current block
+	flat     bool          // never break lines, used to measure the width of code
+}
+
+func (p *printer) print(strs ...string) {
+	for _, s := range strs {
+		p.buf.WriteString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) node(node ast.Noder) {
+	switch node := node.(type) {
+	case *ast.Program:
+		p.empty = true
+		p.statements(node.Statements, math.MaxInt)
+		if !p.empty {
+			p.print("\n")
+		}
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, lowest)
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.print("let ", stmt.Name.Value)
+		if stmt.Type != nil {
+			p.print(": ", stmt.Type.ToString())
+		}
+		p.print(" = ")
+		p.expression(stmt.Value, lowest)
+	case *ast.ReturnStatement:
+		p.print("return ")
+		p.expression(stmt.ReturnValue, lowest)
+	case *ast.ThrowStatement:
+		p.print("throw ")
+		p.expression(stmt.Value, lowest)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, lowest)
+	case *ast.StructStatement:
+		p.print("struct ", stmt.Name.Value, " ")
+		p.names(stmt.Fields)
+	case *ast.TraitStatement:
+		p.print("trait ", stmt.Name.Value, " ")
+		p.names(stmt.Methods)
+	case *ast.EnumStatement:
+		p.print("enum ", stmt.Name.Value, " {")
+		for i, variant := range stmt.Variants {
+			if i > 0 {
+				p.print(",")
+			}
+			p.print(" ", variant.Value)
+			if len(stmt.Fields[i]) > 0 {
+				p.print("(")
+				for j, field := range stmt.Fields[i] {
+					if j > 0 {
+						p.print(", ")
+					}
+					p.print(field.Value)
+				}
+				p.print(")")
+			}
+		}
+		if len(stmt.Variants) > 0 {
+			p.print(" ")
+		}
+		p.print("}")
+	case *ast.ExtendStatement:
+		p.print("extend ", stmt.Type.Value)
+		for i, trait := range stmt.Traits {
+			if i == 0 {
+				p.print(": ", trait.Value)
+			} else {
+				p.print(", ", trait.Value)
+			}
+		}
+		p.print(" ")
+		p.methods(stmt)
+	case *ast.BlockStatement:
+		p.block(stmt)
+		return
+	}
+	// always terminated: an expression such as (a) or -a on the next line
+	// would otherwise continue an expression ending with a block
+	p.print(";")
+}
+
+// statements prints stmts one per line, followed by the comments before
+// the line end, where the enclosing block or program ends.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	for _, stmt := range stmts {
+		start := tokenOf(stmt).Pos.Line
+		p.commentsBefore(start)
+		p.separate(start)
+		p.statement(stmt)
+		p.lastLine = max(p.lastLine, endLine(stmt))
+	}
+	p.commentsBefore(end)
+}
+
+// separate starts a new line for code from the source line, keeping one
+// blank line if there was any before it in the same block.
+func (p *printer) separate(line int) {
+	if p.empty {
+		p.empty = false
+		if p.buf.Len() > 0 {
+			p.newline()
+		}
+		return
+	}
+	if p.lastLine > 0 && line > p.lastLine+1 {
+		p.print("\n")
+	}
+	p.newline()
+}
+
+func (p *printer) commentsBefore(line int) {
+	for len(p.comments) > 0 && p.comments[0].Pos.Line < line {
+		comment := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if comment.Pos.Line <= p.lastLine {
+			p.print(" ", comment.Literal) // trails the code on its line
+			continue
+		}
+		p.separate(comment.Pos.Line)
+		p.print(comment.Literal)
+		p.lastLine = comment.Pos.Line
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	end := block.Rbrace.Pos.Line
+	if len(block.Statements) == 0 && (len(p.comments) == 0 || p.comments[0].Pos.Line >= end) {
+		p.print("{}")
+		return
+	}
+
+	p.print("{")
+	p.indent++
+	empty := p.empty
+	p.empty = true
+	p.lastLine = block.Token.Pos.Line
+	p.statements(block.Statements, end)
+	p.empty = empty
+	p.indent--
+	p.newline()
+	p.print("}")
+	p.lastLine = max(p.lastLine, end)
+}
+
+// names prints the fields of a struct or the methods of a trait, as in { x, y }.
+func (p *printer) names(names []*ast.Identifier) {
+	p.print("{")
+	for i, name := range names {
+		if i > 0 {
+			p.print(",")
+		}
+		p.print(" ", name.Value)
+	}
+	if len(names) > 0 {
+		p.print(" ")
+	}
+	p.print("}")
+}
+
+// methods prints the methods of stmt one per line, the way block prints statements.
+func (p *printer) methods(stmt *ast.ExtendStatement) {
+	end := stmt.Rbrace.Pos.Line
+	if len(stmt.Methods) == 0 && (len(p.comments) == 0 || p.comments[0].Pos.Line >= end) {
+		p.print("{}")
+		return
+	}
+
+	p.print("{")
+	p.indent++
+	empty := p.empty
+	p.empty = true
+	p.lastLine = stmt.Type.Token.Pos.Line
+	for _, method := range stmt.Methods {
+		start := method.Token.Pos.Line
+		p.commentsBefore(start)
+		p.separate(start)
+		p.expression(method, lowest)
+		p.lastLine = max(p.lastLine, endLine(method))
+	}
+	p.commentsBefore(end)
+	p.empty = empty
+	p.indent--
+	p.newline()
+	p.print("}")
+	p.lastLine = max(p.lastLine, end)
+}
+
+// cases prints the cases of a match one per line, the way block prints statements.
+func (p *printer) cases(match *ast.MatchExpression) {
+	p.print("{")
+	p.indent++
+	empty := p.empty
+	p.empty = true
+	p.lastLine = match.Token.Pos.Line
+	for _, c := range match.Cases {
+		start := c.Token.Pos.Line
+		p.commentsBefore(start)
+		p.separate(start)
+		p.print("case ")
+		p.pattern(c.Pattern)
+		if c.Guard != nil {
+			p.print(" if ")
+			p.expression(c.Guard, lowest)
+		}
+		p.print(" ")
+		p.block(c.Body)
+		p.lastLine = max(p.lastLine, endLine(c))
+	}
+	p.commentsBefore(match.Rbrace.Pos.Line)
+	p.empty = empty
+	p.indent--
+	p.newline()
+	p.print("}")
+	p.lastLine = max(p.lastLine, match.Rbrace.Pos.Line)
+}
+
+func (p *printer) pattern(pat ast.Pattern) {
+	switch pat := pat.(type) {
+	case *ast.LiteralPattern:
+		p.expression(pat.Value, lowest)
+	case *ast.VariantPattern:
+		p.print(pat.Name.Value)
+		if len(pat.Fields) > 0 {
+			p.print("(")
+			p.patterns(pat.Fields)
+			p.print(")")
+		}
+	case *ast.ArrayPattern:
+		p.print("[")
+		p.patterns(pat.Elements)
+		p.print("]")
+	default:
+		p.print(pat.ToString())
+	}
+}
+
+func (p *printer) patterns(pats []ast.Pattern) {
+	for i, pat := range pats {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.pattern(pat)
+	}
+}
+
+// expression prints exp, in parentheses if it binds less tightly than min.
+func (p *printer) expression(exp ast.Expression, min int) {
+	if exp == nil {
+		return
+	}
+	if precedence(exp) < min {
+		p.print("(")
+		defer p.print(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.print(exp.Value)
+	case *ast.IntegerLiteral:
+		p.print(strconv.FormatInt(exp.Value, 10))
+	case *ast.Boolean:
+		p.print(strconv.FormatBool(exp.Value))
+	case *ast.StringLiteral:
+		p.print(`"`, exp.Value, `"`)
+
+	case *ast.PrefixExpression:
+		p.print(exp.Operator)
+		p.expression(exp.Right, prefix+1)
+
+	case *ast.InfixExpression:
+		prec := infixPrecedences[exp.Operator]
+		p.expression(exp.Left, prec)
+		p.print(" ", exp.Operator, " ")
+		p.expression(exp.Right, prec+1) // left associative
+
+	case *ast.PostfixExpression:
+		p.expression(exp.Left, call)
+		p.print(exp.Operator)
+
+	case *ast.AssignExpression:
+		p.expression(exp.Target, call)
+		p.print(" = ")
+		p.expression(exp.Value, lowest) // right associative
+
+	case *ast.MemberExpression:
+		p.expression(exp.Object, call)
+		p.print(".", exp.Property.Value)
+
+	case *ast.CallExpression:
+		p.expression(exp.Function, call)
+		p.arguments(exp)
+
+	case *ast.StructLiteral:
+		p.print(exp.Name.Value)
+		starts, ends := []int{}, []int{}
+		for i, field := range exp.Fields {
+			starts = append(starts, field.Token.Pos.Line)
+			ends = append(ends, endLine(exp.Values[i]))
+		}
+		p.list("{", "}", exp.Token.Pos.Line, starts, ends, func(i int) {
+			p.print(exp.Fields[i].Value, ": ")
+			p.expression(exp.Values[i], lowest)
+		})
+
+	case *ast.ArrayLiteral:
+		p.elements("[", "]", exp.Token.Pos.Line, exp.Elements)
+
+	case *ast.IfExpression:
+		p.print("if (")
+		p.expression(exp.Condition, lowest)
+		p.print(") ")
+		p.block(exp.Concequence)
+		if exp.Alternative != nil {
+			if line := exp.Alternative.Token.Pos.Line; len(p.comments) > 0 && p.comments[0].Pos.Line < line {
+				// comments after the closing brace stay there, before the else
+				p.commentsBefore(line)
+				p.newline()
+				p.print("else ")
+			} else {
+				p.print(" else ")
+			}
+			p.block(exp.Alternative)
+		}
+
+	case *ast.MatchExpression:
+		p.print("match (")
+		p.expression(exp.Subject, lowest)
+		p.print(") ")
+		p.cases(exp)
+
+	case *ast.FunctionLiteral:
+		p.print("fn")
+		if exp.Name != nil {
+			p.print(" ", exp.Name.Value)
+		}
+		p.parameters(exp.Parameters, exp.ParameterTypes)
+		if exp.ReturnType != nil {
+			p.print("-> ", exp.ReturnType.ToString(), " ")
+		}
+		p.block(exp.Body)
+
+	case *ast.MacroLiteral:
+		p.print("macro")
+		p.parameters(exp.Parameters, nil)
+		p.block(exp.Body)
+
+	case *ast.TryExpression:
+		p.print("try ")
+		p.block(exp.Block)
+		if exp.Catch != nil {
+			p.print(" catch (", exp.CatchParam.Value, ") ")
+			p.block(exp.Catch)
+		}
+		if exp.Finally != nil {
+			p.print(" finally ")
+			p.block(exp.Finally)
+		}
+	}
+}
+
+func (p *printer) expressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.expression(exp, lowest)
+	}
+}
+
+// arguments prints the arguments of a call, one per line if they do not
+// fit on the line or comments were written among them.
+func (p *printer) arguments(call *ast.CallExpression) {
+	args := call.Arguments
+	if !p.flat && len(args) >= 2 && p.column()+p.width(args) > maxWidth {
+		p.broken("(", ")", call.Token.Pos.Line, lines(args, startLine), lines(args, endLine), func(i int) {
+			p.expression(args[i], lowest)
+		})
+		return
+	}
+	p.elements("(", ")", call.Token.Pos.Line, args)
+}
+
+func (p *printer) elements(open, close string, line int, exps []ast.Expression) {
+	p.list(open, close, line, lines(exps, startLine), lines(exps, endLine), func(i int) {
+		p.expression(exps[i], lowest)
+	})
+}
+
+func lines(exps []ast.Expression, line func(ast.Noder) int) []int {
+	found := []int{}
+	for _, exp := range exps {
+		found = append(found, line(exp))
+	}
+	return found
+}
+
+// list prints the items spanning the source lines starts[i] to ends[i] on
+// one line, unless comments were written between them. open is on line.
+func (p *printer) list(open, close string, line int, starts, ends []int, item func(i int)) {
+	if !p.flat && p.commentsAmong(starts, ends) {
+		p.broken(open, close, line, starts, ends, item)
+		return
+	}
+	p.print(open)
+	for i := range starts {
+		if i > 0 {
+			p.print(", ")
+		}
+		item(i)
+	}
+	p.print(close)
+}
+
+// commentsAmong reports whether comments not printed yet lie between items,
+// rather than within them or after the last one.
+func (p *printer) commentsAmong(starts, ends []int) bool {
+	n := len(starts)
+	for _, comment := range p.comments {
+		if n == 0 || comment.Pos.Line >= ends[n-1] {
+			return false
+		}
+		if comment.Pos.Line < starts[0] {
+			return true
+		}
+		for i := 1; i < n; i++ {
+			if ends[i-1] <= comment.Pos.Line && comment.Pos.Line < starts[i] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// broken prints the items of a list one per line, with the comments before
+// each item either trailing the previous line or on lines of their own.
+func (p *printer) broken(open, close string, line int, starts, ends []int, item func(i int)) {
+	p.print(open)
+	p.indent++
+	p.lastLine = max(p.lastLine, line)
+	for i := range starts {
+		for len(p.comments) > 0 && p.comments[0].Pos.Line < starts[i] {
+			comment := p.comments[0]
+			p.comments = p.comments[1:]
+			if comment.Pos.Line > p.lastLine {
+				p.newline()
+			} else {
+				p.print(" ")
+			}
+			p.print(comment.Literal)
+			p.lastLine = comment.Pos.Line
+		}
+		p.newline()
+		item(i)
+		if i < len(starts)-1 {
+			p.print(",")
+		}
+		p.lastLine = max(p.lastLine, ends[i])
+	}
+	p.indent--
+	p.newline()
+	p.print(close)
+}
+
+// width returns the length of the first line of args printed on one line.
+func (p *printer) width(args []ast.Expression) int {
+	flat := &printer{indent: p.indent, flat: true}
+	flat.print("(")
+	flat.expressions(args)
+	flat.print(")")
+
+	line, _, _ := strings.Cut(flat.buf.String(), "\n")
+	return len(line)
+}
+
+// column returns the width of the line printed so far.
+func (p *printer) column() int {
+	out := p.buf.Bytes()
+	line := out[bytes.LastIndexByte(out, '\n')+1:]
+	return len(line) + bytes.Count(line, []byte("\t"))*(tabWidth-1)
+}
+
+func (p *printer) parameters(params []*ast.Identifier, types []ast.TypeExpression) {
+	p.print("(")
+	for i, param := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(param.Value)
+		if i < len(types) && types[i] != nil {
+			p.print(": ", types[i].ToString())
+		}
+	}
+	p.print(") ")
+}
+
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return infixPrecedences[exp.Operator]
+	case *ast.AssignExpression:
+		return assign
+	case *ast.PrefixExpression:
+		return prefix
+	case *ast.CallExpression, *ast.MemberExpression, *ast.PostfixExpression:
+		return call
+	default:
+		return atom
+	}
+}
+
+func tokenOf(node ast.Noder) token.Token {
+	if field := reflect.ValueOf(node).Elem().FieldByName("Token"); field.IsValid() {
+		return field.Interface().(token.Token)
+	}
+	return token.Token{}
+}
+
+// startLine returns the first source line holding a token of node.
+func startLine(node ast.Noder) int {
+	line := math.MaxInt
+	ast.Inspect(node, func(n ast.Noder) bool {
+		if n == nil {
+			return false
+		}
+		if at := tokenOf(n).Pos.Line; at > 0 {
+			line = min(line, at)
+		}
+		return true
+	})
+	return line
+}
+
+// endLine returns the last source line holding a token of node.
+func endLine(node ast.Noder) int {
+	line := 0
+	ast.Inspect(node, func(n ast.Noder) bool {
+		if n == nil {
+			return false
+		}
+		line = max(line, tokenOf(n).Pos.Line)
+		for _, name := range []string{"Rbrace", "Rparen", "Rbracket"} {
+			if field := reflect.ValueOf(n).Elem().FieldByName(name); field.IsValid() {
+				line = max(line, field.Interface().(token.Token).Pos.Line) // blocks, bodies and lists
+			}
+		}
+		return true
+	})
+	return line
+}
@@ -0,0 +1,198 @@
+package printer
+
+import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"morty/ast"
+	"morty/lexer"
+	"morty/parser"
+	"morty/token"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSprint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 5`, "let x = 5;\n"},
+		{`"hello" + " world"`, "\"hello\" + \" world\";\n"},
+		{`-a * b`, "-a * b;\n"},
+		{`a * -b`, "a * -b;\n"},
+		{`a - (b - c)`, "a - (b - c);\n"},
+		{`(a - b) - c`, "a - b - c;\n"},
+		{`(a + b) * c`, "(a + b) * c;\n"},
+		{`-(-a)`, "-(-a);\n"},
+		{`(-f)(1)`, "(-f)(1);\n"},
+		{`1 + (p.x = 2)`, "1 + (p.x = 2);\n"},
+		{`a.b = c.d = 1`, "a.b = c.d = 1;\n"},
+		{`f(x)? + [1, 2]`, "f(x)? + [1, 2];\n"},
+		{`fn add(a, b) { return a + b; }`, "fn add(a, b) {\n\treturn a + b;\n};\n"},
+		{`if (x) { if (y) { 1 } } else { }`, "if (x) {\n\tif (y) {\n\t\t1;\n\t};\n} else {};\n"},
+		{`try { throw "x" } catch (e) { e.message } finally { 1 }`, "try {\n\tthrow \"x\";\n} catch (e) {\n\te.message;\n} finally {\n\t1;\n};\n"},
+		{`let m = macro(a) { quote(unquote(a)) }`, "let m = macro(a) {\n\tquote(unquote(a));\n};\n"},
+		{`let n: int|null = f( )`, "let n: int | null = f();\n"},
+		{"struct Point {x,y,}\nPoint{ x:1,y : -2 }.x", "struct Point { x, y };\nPoint{x: 1, y: -2}.x;\n"},
+		{`fn add(a:int, b) ->int { a + b }`, "fn add(a: int, b) -> int {\n\ta + b;\n};\n"},
+		{"trait Shape {area}\nextend Point : Shape {\nfn area(self) { self.x * self.y }\n\n// for puts\nfn show(self) { \"p\" } }", "trait Shape { area };\nextend Point: Shape {\n\tfn area(self) {\n\t\tself.x * self.y;\n\t}\n\n\t// for puts\n\tfn show(self) {\n\t\t\"p\";\n\t}\n};\n"},
+		{"enum Shape {Circle(r),Rect(w,h),\nEmpty,}", "enum Shape { Circle(r), Rect(w, h), Empty };\n"},
+		{"let a = match(s){\ncase Circle(r) if r>0 {r*r}\n// none\ncase [\"x\", -1, _] { 0 } case _ {1}\n}", "let a = match (s) {\n\tcase Circle(r) if r > 0 {\n\t\tr * r;\n\t}\n\t// none\n\tcase [\"x\", -1, _] {\n\t\t0;\n\t}\n\tcase _ {\n\t\t1;\n\t}\n};\n"},
+		{`extend STRING{} "a".upper( )`, "extend STRING {};\n\"a\".upper();\n"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if got := Sprint(program); got != tt.expected {
+			t.Errorf("wrong source for %q, expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestRoundTrip checks that parse → print → parse yields an equivalent AST
+// for every string literal in the parser tests that is a valid program.
+func TestRoundTrip(t *testing.T) {
+	inputs := parserTestInputs(t)
+	if len(inputs) < 50 {
+		t.Fatalf("found only %d inputs in the parser tests", len(inputs))
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		printed := Sprint(program)
+
+		l := lexer.New(printed)
+		p := parser.New(l)
+		reparsed := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("printed source of %q does not parse: %q\n%s", input, p.Errors(), printed)
+			continue
+		}
+
+		if !equivalent(reflect.ValueOf(program), reflect.ValueOf(reparsed)) {
+			t.Errorf("round trip of %q changed the AST:\n%s", input, printed)
+		}
+		if again := Sprint(reparsed); again != printed {
+			t.Errorf("printing %q is not stable, got %q then %q", input, printed, again)
+		}
+	}
+}
+
+// TestCommentsInExpressions checks that comments written among the items of
+// a call, array or struct literal stay next to them, and survive printing again.
+func TestCommentsInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let t = add(\n1, // first\n// the second\n2\n); // done",
+			"let t = add(\n\t1, // first\n\t// the second\n\t2\n); // done\n",
+		},
+		{"[1,\n2, // two\n3]", "[\n\t1,\n\t2, // two\n\t3\n];\n"},
+		{"Point{x: 1, // x\ny: 2}", "Point{\n\tx: 1, // x\n\ty: 2\n};\n"},
+		{"f( // args\na)", "f( // args\n\ta\n);\n"},
+		{"spawn(fn() {\n// inside\n1\n}, 2)", "spawn(fn() {\n\t// inside\n\t1;\n}, 2);\n"},
+		{"f(a,\nb); // after", "f(a, b); // after\n"},
+		{"if (x) { 1 } // when x\nelse { 2 }", "if (x) {\n\t1;\n} // when x\nelse {\n\t2;\n};\n"},
+		{"if (x) {\n1\n}\n// otherwise\nelse { 2 }", "if (x) {\n\t1;\n}\n// otherwise\nelse {\n\t2;\n};\n"},
+		{"let a = if (x) { 1 } // one\nelse { 2 } // two", "let a = if (x) {\n\t1;\n} // one\nelse {\n\t2;\n}; // two\n"},
+	}
+
+	for _, tt := range tests {
+		printed := Sprint(parse(t, tt.input))
+		if printed != tt.expected {
+			t.Errorf("wrong source for %q, expected=%q, got=%q", tt.input, tt.expected, printed)
+			continue
+		}
+		if again := Sprint(parse(t, printed)); again != printed {
+			t.Errorf("printing %q is not stable, got %q then %q", tt.input, printed, again)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parsing %q: %q", input, p.Errors())
+	}
+	return program
+}
+
+// parserTestInputs returns the string literals of the parser tests that parse without errors.
+func parserTestInputs(t *testing.T) []string {
+	file, err := goparser.ParseFile(gotoken.NewFileSet(), "../parser/parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("reading parser tests: %s", err)
+	}
+
+	var inputs []string
+	goast.Inspect(file, func(node goast.Node) bool {
+		if _, ok := node.(*goast.ImportSpec); ok {
+			return false
+		}
+		lit, ok := node.(*goast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return true
+		}
+		input, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+		p := parser.New(lexer.New(input))
+		if program := p.ParseProgram(); len(p.Errors()) == 0 && len(program.Statements) > 0 {
+			inputs = append(inputs, input)
+		}
+		return true
+	})
+	return inputs
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+// equivalent compares two ASTs, ignoring tokens, which keep the original spelling.
+func equivalent(a, b reflect.Value) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Kind() == reflect.Interface && a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return equivalent(a.Elem(), b.Elem())
+
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equivalent(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if a.Field(i).Type() == tokenType {
+				continue
+			}
+			if !equivalent(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
@@ -2,17 +2,24 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"morty/ast"
+	"morty/diagnostic"
 	"morty/evaluator"
 	"morty/lexer"
 	"morty/object"
 	"morty/parser"
 	"morty/read"
+	"os"
+	"os/signal"
 )
 
 func Start(file *bufio.Reader, out io.Writer) {
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	builtins := evaluator.NewRegistry()
 	io.WriteString(out, "RESULTS:\n")
 
 	for {
@@ -31,11 +38,21 @@ func Start(file *bufio.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		// Ctrl-C aborts the running line instead of the whole session
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		eval := evaluator.New(
+			evaluator.WithContext(ctx),
+			evaluator.WithBuiltins(builtins),
+			evaluator.WithOutput(out),
+			evaluator.WithCapabilities(evaluator.AllCapabilities...),
+		)
+		evaluated := expandAndEval(eval, program, env, macroEnv)
+		stop()
+
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -43,9 +60,18 @@ func Start(file *bufio.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "  parsing errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func expandAndEval(eval *evaluator.Evaluator, program *ast.Program, env, macroEnv *object.Environment) object.Object {
+	if err := evaluator.DefineMacros(program, macroEnv); err != nil {
+		return err
+	}
+	expanded, err := eval.ExpandMacros(program, macroEnv)
+	if err != nil {
+		return err
 	}
+	return eval.Eval(expanded, env)
+}
+
+func printParserErrors(out io.Writer, line string, diags []diagnostic.Diagnostic) {
+	io.WriteString(out, "  parsing errors:\n")
+	diagnostic.Render(out, "<line>", line, diags)
 }
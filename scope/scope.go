@@ -0,0 +1,405 @@
+// Package scope resolves the identifiers of a program to the bindings they
+// refer to, without running it, for tools such as the language server and
+// the linter.
+package scope
+
+import (
+	"morty/ast"
+	"sort"
+)
+
+type Kind int
+
+const (
+	Let Kind = iota
+	Param
+	Function // a named fn literal
+	CatchParam
+	Builtin
+	Struct
+	Trait
+	Enum
+	Variant
+	PatternVar // a name bound by the pattern of a match case
+)
+
+var kindNames = map[Kind]string{
+	Let:        "let",
+	Param:      "param",
+	Function:   "fn",
+	CatchParam: "catch",
+	Builtin:    "builtin",
+	Struct:     "struct",
+	Trait:      "trait",
+	Enum:       "enum",
+	Variant:    "variant",
+	PatternVar: "pattern",
+}
+
+func (k Kind) String() string { return kindNames[k] }
+
+// Binding is a name declared in a scope.
+type Binding struct {
+	Name  string
+	Kind  Kind
+	Ident *ast.Identifier // the declaring identifier, nil for builtins
+	Node  ast.Noder       // the let, struct, trait or enum statement or function literal declaring the name, if any
+	Scope *Scope
+	Uses  []*ast.Identifier
+	seq   int // when the name is bound, in walk order
+}
+
+// Function returns the function literal bound by b, if it is known statically.
+func (b *Binding) Function() *ast.FunctionLiteral {
+	switch node := b.Node.(type) {
+	case *ast.FunctionLiteral:
+		return node
+	case *ast.LetStatement:
+		fn, _ := node.Value.(*ast.FunctionLiteral)
+		return fn
+	}
+	return nil
+}
+
+// Scope is the region of a program with its own environment at runtime:
+// the program, a function or macro body, a catch block or a match case.
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Noder
+	Start    int // offsets of the region
+	End      int
+	Bindings []*Binding // in source order
+	Children []*Scope
+	seq      int // when the scope is entered, in walk order
+}
+
+// Lookup returns the bindings of name declared directly in s.
+func (s *Scope) Lookup(name string) []*Binding {
+	var found []*Binding
+	for _, b := range s.Bindings {
+		if b.Name == name {
+			found = append(found, b)
+		}
+	}
+	return found
+}
+
+// Visible returns the bindings visible at offset, innermost first, one per name.
+func (s *Scope) Visible(offset int) []*Binding {
+	seen := map[string]bool{}
+	var visible []*Binding
+	for inner := s; inner != nil; inner = inner.Parent {
+		for _, b := range inner.Bindings {
+			if seen[b.Name] || (b.Ident != nil && inner == s && b.Ident.Token.Pos.Offset > offset) {
+				continue
+			}
+			seen[b.Name] = true
+			visible = append(visible, b)
+		}
+	}
+	return visible
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	Universe   *Scope // the builtins, enclosing the program scope
+	Root       *Scope
+	Defs       map[*ast.Identifier]*Binding
+	Uses       map[*ast.Identifier]*Binding
+	Unresolved []*ast.Identifier // uses of names that are declared nowhere
+}
+
+// ScopeAt returns the innermost scope holding offset.
+func (info *Info) ScopeAt(offset int) *Scope {
+	s := info.Root
+	for {
+		next := (*Scope)(nil)
+		for _, child := range s.Children {
+			if child.Start <= offset && offset < child.End {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return s
+		}
+		s = next
+	}
+}
+
+// IdentAt returns the identifier at offset and its binding, declaring it or used there.
+// The binding is nil if the identifier does not resolve.
+func (info *Info) IdentAt(offset int) (*ast.Identifier, *Binding) {
+	for _, m := range []map[*ast.Identifier]*Binding{info.Defs, info.Uses} {
+		for ident, b := range m {
+			if contains(ident, offset) {
+				return ident, b
+			}
+		}
+	}
+	for _, ident := range info.Unresolved {
+		if contains(ident, offset) {
+			return ident, nil
+		}
+	}
+	return nil, nil
+}
+
+func contains(ident *ast.Identifier, offset int) bool {
+	start := ident.Token.Pos.Offset
+	return ident.Token.Pos.Line > 0 && start <= offset && offset <= start+len(ident.Value)
+}
+
+// Resolve resolves the identifiers of program. builtins are the names
+// available when no binding of the program matches.
+func Resolve(program *ast.Program, builtins []string) *Info {
+	universe := &Scope{Start: 0, End: int(^uint(0) >> 1)}
+	for _, name := range builtins {
+		universe.Bindings = append(universe.Bindings, &Binding{Name: name, Kind: Builtin, Scope: universe})
+	}
+	root := &Scope{Parent: universe, Node: program, Start: 0, End: universe.End}
+	universe.Children = []*Scope{root}
+
+	r := &resolver{
+		info:  &Info{Universe: universe, Root: root, Defs: map[*ast.Identifier]*Binding{}, Uses: map[*ast.Identifier]*Binding{}},
+		scope: root,
+	}
+	ast.Walk(program, r)
+
+	for _, use := range r.uses {
+		if b := lookup(use); b != nil {
+			r.info.Uses[use.ident] = b
+			b.Uses = append(b.Uses, use.ident)
+		} else if !use.builtinType {
+			r.info.Unresolved = append(r.info.Unresolved, use.ident)
+		}
+	}
+	sort.Slice(r.info.Unresolved, func(i, j int) bool {
+		return r.info.Unresolved[i].Token.Pos.Offset < r.info.Unresolved[j].Token.Pos.Offset
+	})
+	return r.info
+}
+
+// lookup finds the binding a use refers to the way the evaluator would: in
+// its own scope, the latest name bound before it; in the enclosing scopes,
+// which may have grown by the time a function runs, the latest name bound
+// before the inner scope was entered, or else the first one bound after.
+func lookup(u use) *Binding {
+	at := u.seq
+	s := u.scope
+	for own := true; s != nil; s, own = s.Parent, false {
+		var before, after *Binding
+		for _, b := range s.Lookup(u.ident.Value) {
+			if b.seq < at {
+				before = b
+			} else if after == nil {
+				after = b
+			}
+		}
+		if before != nil {
+			return before
+		}
+		if after != nil && !own {
+			return after
+		}
+		at = s.seq
+	}
+	return nil
+}
+
+type use struct {
+	ident       *ast.Identifier
+	scope       *Scope
+	seq         int
+	builtinType bool // the type of an extend or an annotation, which may also name a builtin type such as STRING or int
+}
+
+type resolver struct {
+	info  *Info
+	scope *Scope
+	uses  []use
+	seq   int
+}
+
+func (r *resolver) next() int {
+	r.seq++
+	return r.seq
+}
+
+func (r *resolver) declare(ident *ast.Identifier, kind Kind, node ast.Noder) {
+	b := &Binding{Name: ident.Value, Kind: kind, Ident: ident, Node: node, Scope: r.scope, seq: r.next()}
+	r.scope.Bindings = append(r.scope.Bindings, b)
+	r.info.Defs[ident] = b
+}
+
+func (r *resolver) enter(node ast.Noder, start int, block *ast.BlockStatement) {
+	end := block.Rbrace.Pos.Offset + 1
+	child := &Scope{Parent: r.scope, Node: node, Start: start, End: end, seq: r.next()}
+	r.scope.Children = append(r.scope.Children, child)
+	r.scope = child
+}
+
+func (r *resolver) leave() {
+	r.scope = r.scope.Parent
+}
+
+// function resolves the parameters and body of fn, in a scope of its own,
+// and its annotations in the enclosing one.
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	for _, typ := range fn.ParameterTypes {
+		if typ != nil {
+			ast.Walk(typ, r)
+		}
+	}
+	if fn.ReturnType != nil {
+		ast.Walk(fn.ReturnType, r)
+	}
+	r.enter(fn, fn.Token.Pos.Offset, fn.Body)
+	for _, param := range fn.Parameters {
+		r.declare(param, Param, nil)
+	}
+	ast.Walk(fn.Body, r)
+	r.leave()
+}
+
+// pattern declares the names pat binds; the variants it names are uses.
+func (r *resolver) pattern(pat ast.Pattern) {
+	switch pat := pat.(type) {
+	case *ast.BindingPattern:
+		r.declare(pat.Name, PatternVar, nil)
+	case *ast.VariantPattern:
+		ast.Walk(pat.Name, r)
+		for _, field := range pat.Fields {
+			r.pattern(field)
+		}
+	case *ast.ArrayPattern:
+		for _, el := range pat.Elements {
+			r.pattern(el)
+		}
+	case *ast.LiteralPattern:
+		ast.Walk(pat.Value, r)
+	}
+}
+
+func (r *resolver) Visit(node ast.Noder) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.uses = append(r.uses, use{ident: node, scope: r.scope, seq: r.next()})
+		return nil
+
+	case *ast.NamedType:
+		ident := &ast.Identifier{Token: node.Token, Value: node.Name}
+		r.uses = append(r.uses, use{ident: ident, scope: r.scope, seq: r.next(), builtinType: true})
+		return nil
+
+	case *ast.LetStatement:
+		if node.Type != nil {
+			ast.Walk(node.Type, r)
+		}
+		if node.Value != nil {
+			ast.Walk(node.Value, r)
+		}
+		r.declare(node.Name, Let, node)
+		return nil
+
+	case *ast.FunctionLiteral:
+		if node.Name != nil {
+			r.declare(node.Name, Function, node)
+		}
+		r.function(node)
+		return nil
+
+	case *ast.MacroLiteral:
+		r.enter(node, node.Token.Pos.Offset, node.Body)
+		for _, param := range node.Parameters {
+			r.declare(param, Param, nil)
+		}
+		ast.Walk(node.Body, r)
+		r.leave()
+		return nil
+
+	case *ast.TryExpression:
+		ast.Walk(node.Block, r)
+		if node.Catch != nil {
+			r.enter(node, node.CatchParam.Token.Pos.Offset, node.Catch)
+			r.declare(node.CatchParam, CatchParam, nil)
+			ast.Walk(node.Catch, r)
+			r.leave()
+		}
+		if node.Finally != nil {
+			ast.Walk(node.Finally, r)
+		}
+		return nil
+
+	case *ast.MemberExpression:
+		ast.Walk(node.Object, r) // the property is not a name
+		return nil
+
+	case *ast.StructStatement:
+		r.declare(node.Name, Struct, node) // the fields are not names
+		return nil
+
+	case *ast.TraitStatement:
+		r.declare(node.Name, Trait, node) // the methods are not names
+		return nil
+
+	case *ast.EnumStatement:
+		r.declare(node.Name, Enum, node)
+		for _, variant := range node.Variants {
+			r.declare(variant, Variant, node) // the fields are not names
+		}
+		return nil
+
+	case *ast.MatchExpression:
+		ast.Walk(node.Subject, r)
+		for _, c := range node.Cases {
+			r.enter(c, c.Token.Pos.Offset, c.Body)
+			r.pattern(c.Pattern)
+			if c.Guard != nil {
+				ast.Walk(c.Guard, r)
+			}
+			ast.Walk(c.Body, r)
+			r.leave()
+		}
+		return nil
+
+	case *ast.ExtendStatement:
+		r.uses = append(r.uses, use{ident: node.Type, scope: r.scope, seq: r.next(), builtinType: true})
+		for _, trait := range node.Traits {
+			ast.Walk(trait, r)
+		}
+		for _, method := range node.Methods {
+			r.function(method) // methods are found by the type of their receiver, not by name
+		}
+		return nil
+
+	case *ast.StructLiteral:
+		ast.Walk(node.Name, r)
+		for _, value := range node.Values {
+			ast.Walk(value, r)
+		}
+		return nil
+
+	case *ast.CallExpression:
+		if fn, ok := node.Function.(*ast.Identifier); ok && fn.Value == "quote" {
+			// quoted code is data, except what is unquoted
+			for _, arg := range node.Arguments {
+				ast.Inspect(arg, func(n ast.Noder) bool {
+					call, ok := n.(*ast.CallExpression)
+					if !ok {
+						return true
+					}
+					if fn, ok := call.Function.(*ast.Identifier); ok && fn.Value == "unquote" {
+						for _, arg := range call.Arguments {
+							ast.Walk(arg, r)
+						}
+						return false
+					}
+					return true
+				})
+			}
+			return nil
+		}
+	}
+	return r
+}
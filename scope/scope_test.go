@@ -0,0 +1,143 @@
+package scope
+
+import (
+	"morty/ast"
+	"morty/lexer"
+	"morty/parser"
+	"strings"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) *Info {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Resolve(program, []string{"len", "puts"})
+}
+
+// at returns the offset of the nth occurrence of name in input, counting from 1.
+func at(input, name string, nth int) int {
+	offset := -1
+	for i := 0; i < nth; i++ {
+		offset += 1 + strings.Index(input[offset+1:], name)
+	}
+	return offset
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		use      int // occurrence of name that is a use
+		decl     int // occurrence of name that declares it, 0 for builtins
+		expected Kind
+	}{
+		{"let x = 1; x + 1;", "x", 2, 1, Let},
+		{"let x = 1; let x = x + 1; x;", "x", 3, 1, Let},
+		{"let x = 1; let x = x + 1; x;", "x", 4, 2, Let},
+		{"let f = fn(a) { a * 2 };", "a", 2, 1, Param},
+		{"let x = 1; let f = fn(x) { x };", "x", 3, 2, Param},
+		{"fn fact(n) { fact(n - 1) }", "fact", 2, 1, Function},
+		{"let f = fn() { g() }; let g = fn() { 1 };", "g", 1, 2, Let},
+		{"try { 1 } catch (e) { e.message }", "e", 2, 1, CatchParam},
+		{"len([1]);", "len", 1, 0, Builtin},
+		{"let len = fn(x) { 0 }; len(1);", "len", 2, 1, Let},
+		{"let m = macro(p) { quote(unquote(p) + b) };", "p", 2, 1, Param},
+		{"struct P { x } P{x: 1};", "P", 2, 1, Struct},
+		{"trait T { f } struct P { x } extend P: T { fn f(self) { self.x } }", "T", 2, 1, Trait},
+		{"trait T { f } struct P { x } extend P: T { fn f(self) { self.x } }", "P", 2, 1, Struct},
+		{"extend STRING { fn f(self) { self } }", "self", 2, 1, Param},
+		{"enum E { A(x), B } match (B) { case A(v) { v } case B { 0 } }", "B", 2, 1, Variant},
+		{"enum E { A(x), B } match (B) { case A(v) { v } case B { 0 } }", "B", 3, 1, Variant},
+		{"enum E { A(x), B } match (B) { case A(v) { v } case B { 0 } }", "v", 2, 1, PatternVar},
+		{"let v = 1; match (2) { case [v, _] if v > 0 { v } case _ { v } }", "v", 4, 2, PatternVar},
+		{"let v = 1; match (2) { case [v, _] if v > 0 { v } case _ { v } }", "v", 5, 1, Let},
+		{"enum E { A } E.A", "E", 2, 1, Enum},
+	}
+
+	for _, tt := range tests {
+		info := resolve(t, tt.input)
+
+		ident, b := info.IdentAt(at(tt.input, tt.name, tt.use))
+		if ident == nil || ident.Value != tt.name {
+			t.Errorf("%q: no identifier %s at occurrence %d", tt.input, tt.name, tt.use)
+			continue
+		}
+		if b == nil {
+			t.Errorf("%q: %s does not resolve", tt.input, tt.name)
+			continue
+		}
+		if b.Kind != tt.expected {
+			t.Errorf("%q: wrong kind. want=%s, got=%s", tt.input, tt.expected, b.Kind)
+		}
+
+		if tt.decl == 0 {
+			if b.Ident != nil {
+				t.Errorf("%q: expected a builtin, got a declaration", tt.input)
+			}
+			continue
+		}
+		if b.Ident == nil || b.Ident.Token.Pos.Offset != at(tt.input, tt.name, tt.decl) {
+			t.Errorf("%q: %s resolves to the wrong declaration %+v", tt.input, tt.name, b.Ident)
+		}
+	}
+}
+
+func TestUnresolved(t *testing.T) {
+	input := "let a = b; let f = fn(x) { x + y }; a.c; quote(z); d; struct S { v } S{v: w}; T{u: 1}; extend STRING: U { fn m(self) { m } }; match (1) { case N(n) if k { n } }"
+	info := resolve(t, input)
+
+	names := []string{}
+	for _, ident := range info.Unresolved {
+		names = append(names, ident.Value)
+	}
+	if strings.Join(names, " ") != "b y d w T U m N k" {
+		t.Errorf("wrong unresolved names, got=%v", names)
+	}
+}
+
+func TestUses(t *testing.T) {
+	input := "let x = 1; let unused = 2; puts(x, x);"
+	info := resolve(t, input)
+
+	for _, b := range info.Root.Bindings {
+		want := map[string]int{"x": 2, "unused": 0}[b.Name]
+		if len(b.Uses) != want {
+			t.Errorf("%s: wrong number of uses. want=%d, got=%d", b.Name, want, len(b.Uses))
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	input := "let a = 1;\nlet f = fn(b) {\n  let c = 2;\n  \n};\nlet d = 3;"
+	info := resolve(t, input)
+
+	inner := info.ScopeAt(at(input, "let c", 1) + 11)
+	if _, ok := inner.Node.(*ast.FunctionLiteral); !ok {
+		t.Fatalf("wrong scope, got=%T", inner.Node)
+	}
+
+	names := []string{}
+	for _, b := range inner.Visible(at(input, "let c", 1) + 11) {
+		names = append(names, b.Name)
+	}
+	if strings.Join(names, " ") != "b c a f d len puts" {
+		t.Errorf("wrong visible names, got=%v", names)
+	}
+
+	if info.ScopeAt(at(input, "let d", 1)) != info.Root {
+		t.Errorf("expected the program scope after the function")
+	}
+}
+
+func TestBindingFunction(t *testing.T) {
+	info := resolve(t, "let f = fn(a, b) { a }; fn g() { 1 }; let h = 1;")
+	for _, b := range info.Root.Bindings {
+		if fn := b.Function(); (fn != nil) != (b.Name != "h") {
+			t.Errorf("%s: wrong function %v", b.Name, fn)
+		}
+	}
+}
@@ -5,11 +5,21 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts in the source
+}
+
+// Position is a place in the source. Line and Column count from 1, and
+// Column counts bytes. The zero Position belongs to tokens made by tools.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
@@ -21,12 +31,17 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	QUESTION = "?"
+	ARROW    = "->"
+	PIPE     = "|"
 
 	LT = "<"
 	GT = ">"
 
 	// Delimiters
 	COMMA     = ","
+	COLON     = ":"
+	DOT       = "."
 	SEMICOLON = ";"
 	LPAREN    = "("
 	RPAREN    = ")"
@@ -42,18 +57,40 @@ const (
 	IF         = "IF"
 	ELSE       = "ELSE"
 	RETURN     = "RETURN"
+	THROW      = "THROW"
+	TRY        = "TRY"
+	CATCH      = "CATCH"
+	FINALLY    = "FINALLY"
+	MACRO      = "MACRO"
+	STRUCT     = "STRUCT"
+	EXTEND     = "EXTEND"
+	TRAIT      = "TRAIT"
+	ENUM       = "ENUM"
+	MATCH      = "MATCH"
+	CASE       = "CASE"
 	EQUALTO    = "=="
 	NOTEQUALTO = "!="
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"macro":   MACRO,
+	"struct":  STRUCT,
+	"extend":  EXTEND,
+	"trait":   TRAIT,
+	"enum":    ENUM,
+	"match":   MATCH,
+	"case":    CASE,
 }
 
 func LookupIdent(ident string) TokenType {
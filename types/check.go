@@ -0,0 +1,536 @@
+package types
+
+import (
+	"morty/ast"
+	"morty/diagnostic"
+	"morty/evaluator"
+	"morty/printer"
+	"morty/scope"
+	"strings"
+)
+
+// Info records the types the checker found.
+type Info struct {
+	Types    map[ast.Expression]Type // of the checked expressions
+	Bindings map[*scope.Binding]Type // of the lets, parameters and named functions
+}
+
+var builtins = evaluator.NewRegistry()
+
+// receiverTypes are the types of the receivers of methods extending builtin types.
+var receiverTypes = map[string]Type{
+	"INTEGER": Int,
+	"STRING":  String,
+	"BOOLEAN": Bool,
+	"NULL":    Null,
+	"ARRAY":   &Array{Elem: Any},
+}
+
+// builtinTypes are the signatures of the builtins that have one; the others are any.
+var builtinTypes = map[string]Type{
+	"len":        &Func{Params: []Type{&Union{Types: []Type{String, &Array{Elem: Any}}}}, Return: Int},
+	"read_file":  &Func{Params: []Type{String}, Return: String},
+	"write_file": &Func{Params: []Type{String, String}, Return: Null},
+	"now":        &Func{Params: []Type{}, Return: Int},
+	"getenv":     &Func{Params: []Type{String}, Return: &Union{Types: []Type{String, Null}}},
+	"is_ok":      &Func{Params: []Type{Any}, Return: Bool},
+	"is_err":     &Func{Params: []Type{Any}, Return: Bool},
+}
+
+// Check infers the types of program and reports the values used where
+// their type does not fit, in source order.
+func Check(program *ast.Program) (*Info, []diagnostic.Diagnostic) {
+	c := &checker{
+		info:  &Info{Types: map[ast.Expression]Type{}, Bindings: map[*scope.Binding]Type{}},
+		refs:  scope.Resolve(program, builtins.Names()),
+		named: map[string]Type{},
+	}
+	ast.Inspect(program, func(n ast.Noder) bool {
+		switch decl := n.(type) {
+		case *ast.StructStatement:
+			c.named[decl.Name.Value] = &Basic{decl.Name.Value}
+		case *ast.TraitStatement:
+			c.named[decl.Name.Value] = Any // any type may gain the methods
+		case *ast.EnumStatement:
+			c.named[decl.Name.Value] = &Basic{decl.Name.Value}
+		}
+		return true
+	})
+	c.statements(program.Statements)
+	return c.info, c.diags
+}
+
+type checker struct {
+	info  *Info
+	refs  *scope.Info
+	diags []diagnostic.Diagnostic
+	named map[string]Type // the structs, traits and enums declared by the program, by name
+	fn    *function       // the function being checked, nil at the top level
+}
+
+type function struct {
+	result  Type   // the declared return type, or nil to infer it
+	returns []Type // the types returned so far
+}
+
+func (c *checker) errorf(code diagnostic.Code, node ast.Noder, format string, a ...interface{}) {
+	c.diags = append(c.diags, diagnostic.Errorf(code, diagnostic.NodeSpan(node), format, a...))
+}
+
+// source returns exp as written, cut after the first line.
+func source(exp ast.Expression) string {
+	src := strings.TrimSuffix(printer.Sprint(exp), "\n")
+	if first, _, ok := strings.Cut(src, "\n"); ok {
+		return first + "...}"
+	}
+	return src
+}
+
+// resolve returns the type an annotation denotes.
+func (c *checker) resolve(typ ast.TypeExpression) Type {
+	switch typ := typ.(type) {
+	case nil:
+		return Any
+	case *ast.NamedType:
+		if basic, ok := Basics[typ.Name]; ok {
+			return basic
+		}
+		if t, ok := c.named[typ.Name]; ok {
+			return t
+		}
+		c.errorf(diagnostic.UnknownType, typ, "unknown type %s", typ.Name)
+		return Any
+	case *ast.ArrayType:
+		return &Array{Elem: c.resolve(typ.Element)}
+	case *ast.FunctionType:
+		fn := &Func{Params: []Type{}, Return: Any}
+		for _, param := range typ.Parameters {
+			fn.Params = append(fn.Params, c.resolve(param))
+		}
+		if typ.Return != nil {
+			fn.Return = c.resolve(typ.Return)
+		}
+		return fn
+	case *ast.UnionType:
+		var members []Type
+		for _, m := range typ.Types {
+			members = append(members, c.resolve(m))
+		}
+		return NewUnion(members...)
+	}
+	return Any
+}
+
+// statements checks stmts and returns the type of the value of the last
+// one, or nil if it never completes, as a return does.
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var last Type = Null
+	for _, stmt := range stmts {
+		last = c.statement(stmt)
+	}
+	return last
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		value := c.expression(stmt.Value)
+		b := c.refs.Defs[stmt.Name]
+		switch {
+		case stmt.Type != nil:
+			declared := c.resolve(stmt.Type)
+			c.assignable(value, declared, stmt.Value, "cannot use %s (%s) as %s value in let %s", source(stmt.Value), value, declared, stmt.Name.Value)
+			c.info.Bindings[b] = declared
+		case value == nil:
+			c.info.Bindings[b] = Any
+		default:
+			c.info.Bindings[b] = value
+		}
+		return Null
+
+	case *ast.ReturnStatement:
+		value := c.expression(stmt.ReturnValue)
+		c.result(value, stmt.ReturnValue)
+		return nil
+
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+		return nil
+
+	case *ast.StructStatement:
+		c.info.Bindings[c.refs.Defs[stmt.Name]] = Any
+		return Null
+
+	case *ast.TraitStatement:
+		c.info.Bindings[c.refs.Defs[stmt.Name]] = Any
+		return Null
+
+	case *ast.EnumStatement:
+		enum := c.named[stmt.Name.Value]
+		c.info.Bindings[c.refs.Defs[stmt.Name]] = Any
+		for i, variant := range stmt.Variants {
+			var t Type = enum
+			if len(stmt.Fields[i]) > 0 {
+				fn := &Func{Params: []Type{}, Return: enum}
+				for range stmt.Fields[i] {
+					fn.Params = append(fn.Params, Any)
+				}
+				t = fn
+			}
+			c.info.Bindings[c.refs.Defs[variant]] = t
+		}
+		return Null
+
+	case *ast.ExtendStatement:
+		recv := receiverTypes[stmt.Type.Value]
+		if t, ok := c.named[stmt.Type.Value]; ok {
+			recv = t
+		}
+		for _, method := range stmt.Methods {
+			c.function(method, recv)
+		}
+		return Null
+
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+	}
+	return Null
+}
+
+// result records a value returned by the current function, exp the expression returned.
+func (c *checker) result(value Type, exp ast.Expression) {
+	if c.fn == nil || value == nil {
+		return
+	}
+	if c.fn.result != nil {
+		c.assignable(value, c.fn.result, exp, "cannot return %s (%s) from a function returning %s", source(exp), value, c.fn.result)
+		return
+	}
+	c.fn.returns = append(c.fn.returns, value)
+}
+
+// assignable reports an error at node unless value is assignable to t.
+func (c *checker) assignable(value, t Type, node ast.Noder, format string, a ...interface{}) bool {
+	if value == nil || AssignableTo(value, t) {
+		return true
+	}
+	code := diagnostic.IncompatibleType
+	if Nullable(value) && AssignableTo(NonNull(value), t) {
+		code = diagnostic.NullableOperand
+	}
+	c.errorf(code, node, format, a...)
+	return false
+}
+
+func (c *checker) expression(exp ast.Expression) Type {
+	if exp == nil {
+		return Any
+	}
+	t := c.infer(exp)
+	c.info.Types[exp] = t
+	return t
+}
+
+func (c *checker) infer(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.ArrayLiteral:
+		var elems []Type
+		for _, elem := range exp.Elements {
+			elems = append(elems, c.expression(elem))
+		}
+		if elem := NewUnion(elems...); elem != nil {
+			return &Array{Elem: elem}
+		}
+		return &Array{Elem: Any}
+
+	case *ast.Identifier:
+		b := c.refs.Uses[exp]
+		if b == nil {
+			c.errorf(diagnostic.UnknownName, exp, "undefined: %s", exp.Value)
+			return Any
+		}
+		if b.Kind == scope.Builtin {
+			if t, ok := builtinTypes[b.Name]; ok {
+				return t
+			}
+			return Any
+		}
+		if t, ok := c.info.Bindings[b]; ok {
+			return t
+		}
+		return Any // bound later, such as a function calling itself
+
+	case *ast.PrefixExpression:
+		right := c.expression(exp.Right)
+		if exp.Operator == "!" {
+			return Bool
+		}
+		c.operand(exp.Right, right, Int, exp)
+		return Int
+
+	case *ast.InfixExpression:
+		return c.infix(exp)
+
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.nonNull(exp.Condition, func() Type {
+			return c.statements(exp.Concequence.Statements)
+		})
+		if exp.Alternative == nil {
+			return NewUnion(consequence, Null)
+		}
+		var negated ast.Expression
+		if not, ok := exp.Condition.(*ast.PrefixExpression); ok && not.Operator == "!" {
+			negated = not.Right
+		}
+		return NewUnion(consequence, c.nonNull(negated, func() Type {
+			return c.statements(exp.Alternative.Statements)
+		}))
+
+	case *ast.FunctionLiteral:
+		return c.function(exp, nil)
+
+	case *ast.CallExpression:
+		return c.call(exp)
+
+	case *ast.AssignExpression:
+		c.expression(exp.Target)
+		return c.expression(exp.Value)
+
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+		return Any
+
+	case *ast.StructLiteral:
+		for _, value := range exp.Values {
+			c.expression(value)
+		}
+		if t, ok := c.named[exp.Name.Value]; ok {
+			return t
+		}
+		return Any
+
+	case *ast.MatchExpression:
+		c.expression(exp.Subject)
+		var types []Type
+		for _, mc := range exp.Cases {
+			c.pattern(mc.Pattern)
+			var subject ast.Expression
+			switch mc.Pattern.(type) {
+			case *ast.BindingPattern, *ast.WildcardPattern:
+			default:
+				subject = exp.Subject // no other pattern matches null
+			}
+			types = append(types, c.nonNull(subject, func() Type {
+				if mc.Guard != nil {
+					c.expression(mc.Guard)
+				}
+				return c.statements(mc.Body.Statements)
+			}))
+		}
+		return NewUnion(types...) // a subject no case fits is an error, not null
+
+	case *ast.PostfixExpression:
+		c.expression(exp.Left)
+		return Any
+
+	case *ast.TryExpression:
+		types := []Type{c.statements(exp.Block.Statements)}
+		if exp.Catch != nil {
+			if b := c.refs.Defs[exp.CatchParam]; b != nil {
+				c.info.Bindings[b] = Any
+			}
+			types = append(types, c.statements(exp.Catch.Statements))
+		}
+		if exp.Finally != nil {
+			c.statements(exp.Finally.Statements)
+		}
+		return NewUnion(types...)
+	}
+
+	// macros are checked once expanded, when they are run
+	return Any
+}
+
+// nonNull runs check with the name exp taken as not null, as it is in a
+// branch only taken when exp is truthy. Other expressions are not narrowed.
+func (c *checker) nonNull(exp ast.Expression, check func() Type) Type {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		return check()
+	}
+	b := c.refs.Uses[ident]
+	t, ok := c.info.Bindings[b]
+	if b == nil || !ok || !Nullable(t) {
+		return check()
+	}
+	c.info.Bindings[b] = NonNull(t)
+	defer func() { c.info.Bindings[b] = t }()
+	return check()
+}
+
+// pattern binds the names pat binds to any.
+func (c *checker) pattern(pat ast.Pattern) {
+	switch pat := pat.(type) {
+	case *ast.BindingPattern:
+		if b := c.refs.Defs[pat.Name]; b != nil {
+			c.info.Bindings[b] = Any
+		}
+	case *ast.LiteralPattern:
+		c.expression(pat.Value)
+	case *ast.VariantPattern:
+		for _, field := range pat.Fields {
+			c.pattern(field)
+		}
+	case *ast.ArrayPattern:
+		for _, el := range pat.Elements {
+			c.pattern(el)
+		}
+	}
+}
+
+// infix mirrors evalInfixExpression: + adds integers or joins strings,
+// the other arithmetic and ordering operators take integers, and == and
+// != compare values of any types.
+func (c *checker) infix(exp *ast.InfixExpression) Type {
+	left := c.expression(exp.Left)
+	right := c.expression(exp.Right)
+
+	switch exp.Operator {
+	case "==", "!=":
+		return Bool
+	case "<", ">":
+		c.operands(exp, left, right, Int)
+		return Bool
+	case "+":
+		if left == Any && right == Any {
+			return Any
+		}
+		if NonNull(left) == String || NonNull(right) == String {
+			c.operands(exp, left, right, String)
+			return String
+		}
+	}
+	c.operands(exp, left, right, Int)
+	return Int
+}
+
+// operands reports an error unless both operands of exp are of type t.
+func (c *checker) operands(exp *ast.InfixExpression, left, right, t Type) {
+	if c.operand(exp.Left, left, t, exp) {
+		c.operand(exp.Right, right, t, exp)
+	}
+}
+
+// operand reports an error at exp unless the operand x of type typ is a t.
+func (c *checker) operand(x ast.Expression, typ, t Type, exp ast.Expression) bool {
+	if typ == nil || AssignableTo(typ, t) {
+		return true
+	}
+	if Nullable(typ) && AssignableTo(NonNull(typ), t) {
+		c.errorf(diagnostic.NullableOperand, x, "invalid operation: %s (%s may be null)", source(exp), source(x))
+		return false
+	}
+	if infix, ok := exp.(*ast.InfixExpression); ok {
+		left, right := c.info.Types[infix.Left], c.info.Types[infix.Right]
+		c.errorf(diagnostic.MismatchedTypes, exp, "invalid operation: %s (mismatched types %s and %s)", source(exp), left, right)
+	} else {
+		c.errorf(diagnostic.MismatchedTypes, exp, "invalid operation: %s (%s is %s, not %s)", source(exp), source(x), typ, t)
+	}
+	return false
+}
+
+// function checks the body of fn and returns its type. The result is the
+// annotated type, or the union of the values the body returns. recv is the
+// type of the receiver of a method, nil for other functions.
+func (c *checker) function(fn *ast.FunctionLiteral, recv Type) Type {
+	typ := &Func{Params: []Type{}, Return: Any}
+	for i, param := range fn.Parameters {
+		t := c.resolve(fn.ParameterType(i))
+		if i == 0 && recv != nil && fn.ParameterType(i) == nil {
+			t = recv
+		}
+		typ.Params = append(typ.Params, t)
+		if b := c.refs.Defs[param]; b != nil {
+			c.info.Bindings[b] = t
+		}
+	}
+
+	outer := c.fn
+	c.fn = &function{}
+	if fn.ReturnType != nil {
+		typ.Return = c.resolve(fn.ReturnType)
+		c.fn.result = typ.Return
+	}
+	if b := c.refs.Defs[fn.Name]; b != nil {
+		// visible to the body, for recursive calls
+		c.info.Bindings[b] = typ
+	}
+
+	// the value of the last statement is returned too
+	last := c.statements(fn.Body.Statements)
+	if n := len(fn.Body.Statements); n > 0 {
+		if stmt, ok := fn.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.result(last, stmt.Expression)
+			last = nil
+		}
+	}
+	if last != nil && c.fn.result != nil && !AssignableTo(last, c.fn.result) {
+		c.errorf(diagnostic.IncompatibleType, fn.Body, "missing return at the end of a function returning %s", c.fn.result)
+	} else if last != nil {
+		c.fn.returns = append(c.fn.returns, last)
+	}
+	if fn.ReturnType == nil {
+		if result := NewUnion(c.fn.returns...); result != nil {
+			typ.Return = result
+		}
+	}
+	c.fn = outer
+	return typ
+}
+
+func (c *checker) call(call *ast.CallExpression) Type {
+	if ident, ok := call.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+		return Any // quoted code is data
+	}
+
+	callee := c.expression(call.Function)
+	var args []Type
+	for _, arg := range call.Arguments {
+		args = append(args, c.expression(arg))
+	}
+
+	if callee == nil || callee == Any {
+		return Any
+	}
+	fn, ok := callee.(*Func)
+	if !ok {
+		if Nullable(callee) {
+			if fn, ok := NonNull(callee).(*Func); ok {
+				c.errorf(diagnostic.NullableOperand, call.Function, "cannot call %s (%s may be null)", source(call.Function), source(call.Function))
+				return fn.Return
+			}
+		}
+		c.errorf(diagnostic.NotCallable, call.Function, "cannot call non-function %s (%s)", source(call.Function), callee)
+		return Any
+	}
+
+	if len(args) != len(fn.Params) {
+		c.errorf(diagnostic.ArgumentCount, call, "wrong number of arguments in call to %s: got %d, want %d", source(call.Function), len(args), len(fn.Params))
+		return fn.Return
+	}
+	for i, arg := range call.Arguments {
+		c.assignable(args[i], fn.Params[i], arg, "cannot use %s (%s) as %s in argument %d to %s", source(arg), args[i], fn.Params[i], i+1, source(call.Function))
+	}
+	return fn.Return
+}
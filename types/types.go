@@ -0,0 +1,224 @@
+// Package types checks the optional type annotations of a program without
+// running it. Types are inferred locally: unannotated parameters are any,
+// and lets and function results take the type of their value.
+package types
+
+import (
+	"strings"
+)
+
+// Type is the static type of a value.
+type Type interface {
+	String() string
+}
+
+// Basic is a type denoted by a name, such as int or the name of a struct.
+type Basic struct {
+	name string
+}
+
+func (b *Basic) String() string { return b.name }
+
+var (
+	Int    = &Basic{"int"}
+	String = &Basic{"string"}
+	Bool   = &Basic{"bool"}
+	Null   = &Basic{"null"}
+	Any    = &Basic{"any"} // any value, checked at runtime only
+)
+
+// Basics are the named types, by name.
+var Basics = map[string]*Basic{
+	Int.name:    Int,
+	String.name: String,
+	Bool.name:   Bool,
+	Null.name:   Null,
+	Any.name:    Any,
+}
+
+// Array is an array of elements of type Elem.
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return "[" + a.Elem.String() + "]" }
+
+// Func is the type of functions and closures.
+type Func struct {
+	Params []Type
+	Return Type
+}
+
+func (f *Func) String() string {
+	params := []string{}
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// Union is a value of one of Types, such as int | null.
+type Union struct {
+	Types []Type
+}
+
+func (u *Union) String() string {
+	types := []string{}
+	for _, t := range u.Types {
+		if _, ok := t.(*Func); ok {
+			types = append(types, "("+t.String()+")")
+		} else {
+			types = append(types, t.String())
+		}
+	}
+	return strings.Join(types, " | ")
+}
+
+// NewUnion returns the union of types, flattening nested unions and
+// dropping duplicates and nil types, which stand for no value. It returns
+// the only type left if there is one, Any if any is among types and nil if
+// none is left.
+func NewUnion(types ...Type) Type {
+	var members []Type
+	var add func(t Type)
+	add = func(t Type) {
+		switch t := t.(type) {
+		case nil:
+		case *Union:
+			for _, m := range t.Types {
+				add(m)
+			}
+		default:
+			if !contains(members, t) {
+				members = append(members, t)
+			}
+		}
+	}
+	for _, t := range types {
+		add(t)
+	}
+
+	for _, m := range members {
+		if m == Any {
+			return Any
+		}
+	}
+	switch len(members) {
+	case 0:
+		return nil
+	case 1:
+		return members[0]
+	}
+	return &Union{Types: members}
+}
+
+// Identical reports whether a and b are the same type.
+func Identical(a, b Type) bool {
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && Identical(a.Elem, b.Elem)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !Identical(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return Identical(a.Return, b.Return)
+	case *Union:
+		b, ok := b.(*Union)
+		if !ok || len(a.Types) != len(b.Types) {
+			return false
+		}
+		for _, m := range a.Types {
+			if !contains(b.Types, m) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func contains(types []Type, t Type) bool {
+	for _, m := range types {
+		if Identical(m, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssignableTo reports whether a value of type v may be used where a t is
+// expected. Any is assignable both ways; a union is assignable if all its
+// members are, and a type is assignable to a union if it is to one member.
+func AssignableTo(v, t Type) bool {
+	if v == Any || t == Any {
+		return true
+	}
+	if u, ok := v.(*Union); ok {
+		for _, m := range u.Types {
+			if !AssignableTo(m, t) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch t := t.(type) {
+	case *Basic:
+		return v == t
+	case *Array:
+		va, ok := v.(*Array)
+		return ok && AssignableTo(va.Elem, t.Elem)
+	case *Func:
+		vf, ok := v.(*Func)
+		if !ok || len(vf.Params) != len(t.Params) {
+			return false
+		}
+		for i := range t.Params {
+			if !AssignableTo(t.Params[i], vf.Params[i]) {
+				return false
+			}
+		}
+		return AssignableTo(vf.Return, t.Return)
+	case *Union:
+		for _, m := range t.Types {
+			if AssignableTo(v, m) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Nullable reports whether t is a union with null among its members.
+func Nullable(t Type) bool {
+	if u, ok := t.(*Union); ok {
+		for _, m := range u.Types {
+			if m == Null {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NonNull returns t without null.
+func NonNull(t Type) Type {
+	u, ok := t.(*Union)
+	if !ok {
+		return t
+	}
+	var members []Type
+	for _, m := range u.Types {
+		if m != Null {
+			members = append(members, m)
+		}
+	}
+	return NewUnion(members...)
+}
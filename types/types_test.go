@@ -0,0 +1,192 @@
+package types
+
+import (
+	"fmt"
+	"morty/ast"
+	"morty/lexer"
+	"morty/parser"
+	"testing"
+)
+
+func check(t *testing.T, input string) (*ast.Program, *Info, []string) {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	info, diags := Check(program)
+	found := []string{}
+	for _, d := range diags {
+		start := d.Primary.Span.Start
+		found = append(found, fmt.Sprintf("%s %d:%d %s", d.Code, start.Line, start.Column, d.Message))
+	}
+	return program, info, found
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "int"},
+		{`"a" + "b"`, "string"},
+		{"1 < 2", "bool"},
+		{"!5", "bool"},
+		{"[1, 2]", "[int]"},
+		{`[1, "a", 2]`, "[int | string]"},
+		{"[]", "[any]"},
+		{"if (x) { 1 }", "int | null"},
+		{`if (x) { 1 } else { "a" }`, "int | string"},
+		{"let x = 5; x", "int"},
+		{"let x: any = 5; x", "any"},
+		{"fn(a, b) { a + b }", "fn(any, any) -> any"},
+		{"fn(a: int, b: int) { a + b }", "fn(int, int) -> int"},
+		{`fn(a: int) { if (a > 0) { return "pos" } a }`, "fn(int) -> string | int"},
+		{"fn(a: int) -> int | null { a }", "fn(int) -> int | null"},
+		{"let add = fn(a: int) { fn(b: int) { a + b } }; add(1)", "fn(int) -> int"},
+		{"fn fact(n: int) -> int { if (n < 2) { return 1 } n * fact(n - 1) }; fact", "fn(int) -> int"},
+		{`getenv("HOME")`, "string | null"},
+		{"len", "fn(string | [any]) -> int"},
+		{"let f: fn(int) -> bool = fn(x) { true }; f", "fn(int) -> bool"},
+		{`try { 1 } catch (e) { "x" }`, "int | string"},
+		{"struct Point { x, y } Point{x: 1, y: 2}", "Point"},
+		{"struct Point { x, y } let p = Point{x: 1, y: 2}; p.x", "any"},
+		{`"abc".upper()`, "any"},
+		{"enum Shape { Circle(r), Empty } Circle(1)", "Shape"},
+		{"enum Shape { Circle(r), Empty } Empty", "Shape"},
+		{"enum Shape { Circle(r), Empty } Circle", "fn(any) -> Shape"},
+		{`match (1) { case 1 { "one" } case n if n > 1 { 2 } }`, "string | int"},
+	}
+
+	for _, tt := range tests {
+		program, info, _ := check(t, tt.input)
+		last := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+		if got := info.Types[last.Expression]; got == nil || got.String() != tt.expected {
+			t.Errorf("wrong type for %q, want=%s, got=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x: int = 5; let s: string = \"a\"; x + 1; s + \"b\"", nil},
+		{`let x: int = "five";`, []string{`E0406 1:14 cannot use "five" (string) as int value in let x`}},
+		{"let x: integer = 1;", []string{"E0401 1:8 unknown type integer"}},
+		{`1 + "a"; "a" - "b"; -"c"; 1 < true`, []string{
+			`E0402 1:1 invalid operation: 1 + "a" (mismatched types int and string)`,
+			`E0402 1:10 invalid operation: "a" - "b" (mismatched types string and string)`,
+			`E0402 1:21 invalid operation: -"c" ("c" is string, not int)`,
+			"E0402 1:27 invalid operation: 1 < true (mismatched types int and bool)",
+		}},
+		{`let home = getenv("HOME"); home + "/bin"`, []string{`E0403 1:28 invalid operation: home + "/bin" (home may be null)`}},
+		{"let x = true; let n = if (x) { 1 }; let m: int = n;", []string{"E0403 1:50 cannot use n (int | null) as int value in let m"}},
+		{`let home = getenv("HOME"); if (home) { home + "/bin" }; if (!home) { "/" } else { home + "/bin" }`, nil},
+		{`let home = getenv("HOME"); match (home) { case "/" { home + "x" } case h { home + "x" } }`, []string{
+			`E0403 1:76 invalid operation: home + "x" (home may be null)`,
+		}},
+		{"let z: int | null = null; y + 1", []string{"E0407 1:21 undefined: null", "E0407 1:27 undefined: y"}},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(1); add(1, \"2\"); add(1, 2)", []string{
+			"E0405 1:48 wrong number of arguments in call to add: got 1, want 2",
+			`E0406 1:63 cannot use "2" (string) as int in argument 2 to add`,
+		}},
+		{"fn f(a) { a } f(1, 2)", []string{"E0405 1:15 wrong number of arguments in call to f: got 2, want 1"}},
+		{"let x = 1; x(2); \"s\"()", []string{
+			"E0404 1:12 cannot call non-function x (int)",
+			`E0404 1:18 cannot call non-function "s" (string)`,
+		}},
+		{"fn f() -> int { \"a\" }", []string{`E0406 1:17 cannot return "a" (string) from a function returning int`}},
+		{"fn f(x) -> int { if (x) { return true } 1 }", []string{"E0406 1:34 cannot return true (bool) from a function returning int"}},
+		{"fn f() -> int { let x = 1; }", []string{"E0406 1:15 missing return at the end of a function returning int"}},
+		{"fn f(x) -> int | null { if (x) { 1 } }", nil},
+		{"let apply = fn(f: fn(int) -> int, x: int) { f(x) }; apply(fn(x: int) { x * 2 }, 1); apply(fn(s: string) { s }, 1)", []string{
+			"E0406 1:91 cannot use fn(s: string) {...} (fn(string) -> string) as fn(int) -> int in argument 1 to apply",
+		}},
+		{"let f = fn(x) { x }; f(1) + f(\"a\"); puts(1, 2); unwrap(ok(1)) + 1", nil},
+		{"let m = macro(a) { quote(unquote(a) + \"x\" - 1) }; m(1)", nil},
+		{"struct Point { x, y } fn norm(p: Point) -> int { p.x * p.x + p.y * p.y } norm(Point{x: 3, y: 4}); norm(3)", []string{
+			"E0406 1:104 cannot use 3 (int) as Point in argument 1 to norm",
+		}},
+		{"extend STRING { fn shout(self) { self - \"!\" } }", []string{
+			`E0402 1:34 invalid operation: self - "!" (mismatched types string and string)`,
+		}},
+		{"trait Shape { area } struct Sq { s } fn total(a: Shape) -> int { a.area() } total(Sq{s: 1}); total(\"x\")", nil},
+		{"enum Shape { Circle(r), Empty } fn f(s: Shape) { s } f(Empty); f(Circle(2)); f(Circle(1, 2)); f(\"x\")", []string{
+			"E0405 1:80 wrong number of arguments in call to Circle: got 2, want 1",
+			`E0406 1:97 cannot use "x" (string) as Shape in argument 1 to f`,
+		}},
+		{`match ([1]) { case [x] if x - "a" { 1 } case _ { 2 } }`, []string{
+			`E0402 1:27 invalid operation: x - "a" (mismatched types any and string)`,
+		}},
+		{"struct Point { x, y } Point{x: 1, y: 2} + 1", []string{
+			"E0402 1:23 invalid operation: Point{x: 1, y: 2} + 1 (mismatched types Point and int)",
+		}},
+	}
+
+	for _, tt := range tests {
+		_, _, found := check(t, tt.input)
+		if len(found) != len(tt.expected) {
+			t.Errorf("wrong errors for %q, want=%q, got=%q", tt.input, tt.expected, found)
+			continue
+		}
+		for i := range found {
+			if found[i] != tt.expected[i] {
+				t.Errorf("wrong error for %q, want=%q, got=%q", tt.input, tt.expected[i], found[i])
+			}
+		}
+	}
+}
+
+func TestAssignableTo(t *testing.T) {
+	intOrNull := NewUnion(Int, Null)
+	tests := []struct {
+		v, t     Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, String, false},
+		{Any, Int, true},
+		{Int, Any, true},
+		{Int, intOrNull, true},
+		{intOrNull, Int, false},
+		{intOrNull, NewUnion(Null, Int, String), true},
+		{&Array{Elem: Int}, &Array{Elem: intOrNull}, true},
+		{&Array{Elem: intOrNull}, &Array{Elem: Int}, false},
+		{&Func{Params: []Type{intOrNull}, Return: Int}, &Func{Params: []Type{Int}, Return: intOrNull}, true},
+		{&Func{Params: []Type{Int}, Return: Int}, &Func{Params: []Type{intOrNull}, Return: Int}, false},
+		{&Func{Params: []Type{}, Return: Int}, &Func{Params: []Type{Int}, Return: Int}, false},
+	}
+
+	for _, tt := range tests {
+		if got := AssignableTo(tt.v, tt.t); got != tt.expected {
+			t.Errorf("AssignableTo(%s, %s) = %t, want %t", tt.v, tt.t, got, tt.expected)
+		}
+	}
+}
+
+func TestNewUnion(t *testing.T) {
+	tests := []struct {
+		types    []Type
+		expected string
+	}{
+		{[]Type{Int, Null, Int}, "int | null"},
+		{[]Type{NewUnion(Int, Null), NewUnion(String, Null)}, "int | null | string"},
+		{[]Type{Int, Any}, "any"},
+		{[]Type{Int, nil}, "int"},
+		{[]Type{&Array{Elem: Int}, &Array{Elem: Int}}, "[int]"},
+		{[]Type{&Func{Params: []Type{}, Return: Int}, Null}, "(fn() -> int) | null"},
+	}
+
+	for _, tt := range tests {
+		if got := NewUnion(tt.types...).String(); got != tt.expected {
+			t.Errorf("wrong union, want=%s, got=%s", tt.expected, got)
+		}
+	}
+	if NewUnion(nil) != nil {
+		t.Errorf("union of no values is not nil")
+	}
+}